@@ -0,0 +1,43 @@
+package internal
+
+// Enricher provides metadata about the environment the tracker runs in.
+// The attributes are added to the common blocks of the forwarded logs
+// and metrics.
+type Enricher interface {
+	Attributes() map[string]string
+}
+
+// EnricherFunc adapts a function to the Enricher interface.
+type EnricherFunc func() map[string]string
+
+func (f EnricherFunc) Attributes() map[string]string {
+	return f()
+}
+
+// EnrichMissing adds only the attributes of the enrichers which are not
+// present yet, so that given attributes take precedence.
+func EnrichMissing(
+	attrs map[string]string,
+	enrichers ...Enricher,
+) {
+	for _, e := range enrichers {
+		for key, val := range e.Attributes() {
+			if _, ok := attrs[key]; !ok {
+				attrs[key] = val
+			}
+		}
+	}
+}
+
+// Enrich adds the attributes of all enrichers to the given ones.
+// Later enrichers override the attributes of earlier ones.
+func Enrich(
+	attrs map[string]string,
+	enrichers ...Enricher,
+) {
+	for _, e := range enrichers {
+		for key, val := range e.Attributes() {
+			attrs[key] = val
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+const MODULE_PATH = "github.com/utr1903/newrelic-tracker-internal"
+
+var (
+	runId     string
+	runIdOnce sync.Once
+)
+
+// RunId returns the ID which identifies the current process across
+// all of its logs and metrics.
+func RunId() string {
+	runIdOnce.Do(func() {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return
+		}
+		runId = hex.EncodeToString(b)
+	})
+	return runId
+}
+
+// HostEnricher provides the metadata of the host and the process.
+type HostEnricher struct{}
+
+func NewHostEnricher() *HostEnricher {
+	return &HostEnricher{}
+}
+
+func (e *HostEnricher) Attributes() map[string]string {
+	attrs := map[string]string{
+		"process.pid":             strconv.Itoa(os.Getpid()),
+		"process.runtime.name":    "go",
+		"process.runtime.version": runtime.Version(),
+		"instrumentation.version": libraryVersion(),
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		attrs["hostname"] = hostname
+	}
+
+	if executable, err := os.Executable(); err == nil {
+		attrs["process.executable.name"] = filepath.Base(executable)
+	}
+
+	if id := RunId(); id != "" {
+		attrs["tracker.run.id"] = id
+	}
+
+	return attrs
+}
+
+// libraryVersion looks up the version of this module within the build
+// information of the binary.
+func libraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	if info.Main.Path == MODULE_PATH {
+		return info.Main.Version
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == MODULE_PATH {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HostEnricherProvidesProcessMetadata(t *testing.T) {
+	attrs := NewHostEnricher().Attributes()
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, attrs["hostname"])
+	assert.Equal(t, strconv.Itoa(os.Getpid()), attrs["process.pid"])
+	assert.Equal(t, "go", attrs["process.runtime.name"])
+	assert.NotEmpty(t, attrs["process.executable.name"])
+	assert.NotEmpty(t, attrs["instrumentation.version"])
+}
+
+func Test_RunIdIsStablePerProcess(t *testing.T) {
+	assert.Len(t, RunId(), 32)
+	assert.Equal(t, RunId(), NewHostEnricher().Attributes()["tracker.run.id"])
+}
+
+func Test_EnrichMissingKeepsGivenAttributes(t *testing.T) {
+	attrs := map[string]string{"hostname": "given"}
+
+	EnrichMissing(attrs, NewHostEnricher())
+
+	assert.Equal(t, "given", attrs["hostname"])
+	assert.Equal(t, "go", attrs["process.runtime.name"])
+}
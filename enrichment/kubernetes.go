@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const DEFAULT_POD_INFO_DIR = "/etc/podinfo"
+
+// The scheduled time in minutes which CronJobs append to their jobs
+var cronJobSuffix = regexp.MustCompile(`-\d{8,}$`)
+
+// KubernetesEnricher reads the pod metadata which is exposed via
+// environment variables and Downward API volume files.
+//
+// Environment variables: NODE_NAME, NAMESPACE_NAME, POD_NAME, POD_UID,
+// CONTAINER_NAME and CLUSTER_NAME.
+//
+// Downward API files within the pod info directory: labels and
+// annotations. Only the labels and annotations of the allowlists are
+// added since annotations like the last applied configuration are large
+// and may contain secrets. The job name labels are always read to
+// derive the names of the Job and the CronJob.
+type KubernetesEnricher struct {
+	PodInfoDir  string
+	Labels      []string
+	Annotations []string
+}
+
+// KubernetesOption configures the enricher created by
+// NewKubernetesEnricher.
+type KubernetesOption func(*KubernetesEnricher)
+
+// WithLabels adds the pod labels with the given keys as label.<key>.
+func WithLabels(
+	keys ...string,
+) KubernetesOption {
+	return func(e *KubernetesEnricher) {
+		e.Labels = append(e.Labels, keys...)
+	}
+}
+
+// WithAnnotations adds the pod annotations with the given keys as
+// annotation.<key>.
+func WithAnnotations(
+	keys ...string,
+) KubernetesOption {
+	return func(e *KubernetesEnricher) {
+		e.Annotations = append(e.Annotations, keys...)
+	}
+}
+
+func NewKubernetesEnricher(
+	opts ...KubernetesOption,
+) *KubernetesEnricher {
+	e := &KubernetesEnricher{
+		PodInfoDir: DEFAULT_POD_INFO_DIR,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+func (e *KubernetesEnricher) Attributes() map[string]string {
+	attrs := make(map[string]string)
+
+	envs := map[string]string{
+		"NODE_NAME":      "nodeName",
+		"NAMESPACE_NAME": "namespaceName",
+		"POD_NAME":       "podName",
+		"POD_UID":        "podUid",
+		"CONTAINER_NAME": "containerName",
+		"CLUSTER_NAME":   "clusterName",
+	}
+	for env, key := range envs {
+		if val := os.Getenv(env); val != "" {
+			attrs[key] = val
+		}
+	}
+
+	labels := readDownwardApiFile(filepath.Join(e.PodInfoDir, "labels"))
+	for _, key := range e.Labels {
+		if val, ok := labels[key]; ok {
+			attrs["label."+key] = val
+		}
+	}
+
+	if len(e.Annotations) > 0 {
+		annotations := readDownwardApiFile(filepath.Join(e.PodInfoDir, "annotations"))
+		for _, key := range e.Annotations {
+			if val, ok := annotations[key]; ok {
+				attrs["annotation."+key] = val
+			}
+		}
+	}
+
+	// Pods of Jobs are labeled with the name of their Job which carries
+	// the name of the CronJob when it was scheduled by one
+	jobName := labels["batch.kubernetes.io/job-name"]
+	if jobName == "" {
+		jobName = labels["job-name"]
+	}
+	if jobName != "" {
+		attrs["jobName"] = jobName
+
+		if cronJobSuffix.MatchString(jobName) {
+			attrs["cronJobName"] = cronJobSuffix.ReplaceAllString(jobName, "")
+		}
+	}
+
+	return attrs
+}
+
+// readDownwardApiFile parses the key="value" lines of a Downward API
+// file. Missing or malformed files result in no attributes.
+func readDownwardApiFile(
+	path string,
+) map[string]string {
+	attrs := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return attrs
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		if unquoted, err := strconv.Unquote(val); err == nil {
+			val = unquoted
+		}
+		attrs[key] = val
+	}
+
+	return attrs
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KubernetesEnricherReadsEnvironmentVariables(t *testing.T) {
+	t.Setenv("POD_NAME", "pod")
+	t.Setenv("CONTAINER_NAME", "container")
+
+	e := &KubernetesEnricher{
+		PodInfoDir: t.TempDir(),
+	}
+	attrs := e.Attributes()
+
+	assert.Equal(t, "pod", attrs["podName"])
+	assert.Equal(t, "container", attrs["containerName"])
+}
+
+func Test_KubernetesEnricherReadsDownwardApiFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "labels"), []byte(
+		"app=\"tracker\"\njob-name=\"usage-tracker-27934560\"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "annotations"), []byte(
+		"team=\"observability\"\n"), 0644)
+
+	e := NewKubernetesEnricher(
+		WithLabels("app"),
+		WithAnnotations("team"),
+	)
+	e.PodInfoDir = dir
+	attrs := e.Attributes()
+
+	assert.Equal(t, "tracker", attrs["label.app"])
+	assert.Equal(t, "observability", attrs["annotation.team"])
+	assert.Equal(t, "usage-tracker-27934560", attrs["jobName"])
+	assert.Equal(t, "usage-tracker", attrs["cronJobName"])
+}
+
+func Test_KubernetesEnricherAddsOnlyAllowedLabelsAndAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "labels"), []byte(
+		"app=\"tracker\"\njob-name=\"usage-tracker-27934560\"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "annotations"), []byte(
+		"kubectl.kubernetes.io/last-applied-configuration=\"{}\"\nteam=\"observability\"\n"), 0644)
+
+	e := &KubernetesEnricher{
+		PodInfoDir: dir,
+	}
+	attrs := e.Attributes()
+
+	for key := range attrs {
+		assert.NotContains(t, key, "label.")
+		assert.NotContains(t, key, "annotation.")
+	}
+	assert.Equal(t, "usage-tracker", attrs["cronJobName"])
+}
+
+func Test_KubernetesEnricherIgnoresMissingFiles(t *testing.T) {
+	e := &KubernetesEnricher{
+		PodInfoDir: filepath.Join(t.TempDir(), "missing"),
+	}
+	attrs := e.Attributes()
+
+	_, ok := attrs["jobName"]
+	assert.False(t, ok)
+}
+
+func Test_EnrichOverridesInOrder(t *testing.T) {
+	attrs := map[string]string{"key": "given"}
+
+	Enrich(attrs,
+		EnricherFunc(func() map[string]string {
+			return map[string]string{"key": "first"}
+		}),
+		EnricherFunc(func() map[string]string {
+			return map[string]string{"key": "second"}
+		}),
+	)
+
+	assert.Equal(t, "second", attrs["key"])
+}
@@ -8,6 +8,10 @@ const (
 	FETCHER_GRAPHQL_HAS_RETURNED_ERRORS = "graphql has returned errors"
 )
 
+// Fetch executes the query of the client and parses the response into
+// res. Heavy NRQL queries which exceed the synchronous timeout of
+// NerdGraph can be fetched with a graphql.AsyncNrqlClient instead, the
+// results are parsed the same way.
 func Fetch(
 	gqlc graphql.IGraphQlClient,
 	qv any,
@@ -15,6 +19,8 @@ func Fetch(
 ) error {
 	err := gqlc.Execute(qv, res)
 	if err != nil {
+		// Typed errors like *graphql.ResponseError are returned as is
+		// to keep them accessible via errors.As
 		return err
 	}
 	return nil
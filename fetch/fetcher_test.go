@@ -3,13 +3,18 @@ package internal
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	graphql "github.com/utr1903/newrelic-tracker-internal/graphql"
+	logging "github.com/utr1903/newrelic-tracker-internal/logging"
 )
 
 type graphqlClientMock struct {
-	failRequest bool
+	failRequest  bool
+	returnErrors bool
 }
 
 func (c *graphqlClientMock) Execute(
@@ -20,6 +25,14 @@ func (c *graphqlClientMock) Execute(
 		return errors.New("error")
 	}
 
+	if c.returnErrors {
+		return &graphql.ResponseError{
+			Errors: []graphql.GraphQlError{{
+				Message: "error",
+			}},
+		}
+	}
+
 	// Create mock response to convert into bytes
 	responseMock := map[string]string{
 		"test": "test",
@@ -49,6 +62,19 @@ func Test_GraphQlRequestFails(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func Test_GraphQlReturnsErrors(t *testing.T) {
+	gqlc := &graphqlClientMock{
+		returnErrors: true,
+	}
+
+	res := map[string]string{}
+	err := Fetch(gqlc, "qv", &res)
+
+	var respErr *graphql.ResponseError
+	assert.ErrorAs(t, err, &respErr)
+	assert.Equal(t, graphql.GRAPHQL_RESPONSE_HAS_RETURNED_ERRORS+": error", err.Error())
+}
+
 func Test_GraphQlRequestSucceeds(t *testing.T) {
 	gqlc := &graphqlClientMock{
 		failRequest: false,
@@ -59,3 +85,38 @@ func Test_GraphQlRequestSucceeds(t *testing.T) {
 	assert.NotNil(t, res)
 	assert.Nil(t, err)
 }
+
+func Test_AsyncNrqlQuerySucceeds(t *testing.T) {
+	newrelicGraphQlServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"data": {"actor": {"account": {"nrql": {
+				"results": [{"count": 42}],
+				"queryProgress": {"queryId": "query-id", "completed": true}
+			}}}}}`))
+		}))
+	defer newrelicGraphQlServerMock.Close()
+
+	gqlc := graphql.NewAsyncNrqlClient(
+		logging.NewNoopLogger(),
+		newrelicGraphQlServerMock.URL,
+		graphql.WithCredentials(graphql.StaticApiKey("key")),
+	)
+
+	res := &graphql.Response[struct {
+		Actor struct {
+			Account struct {
+				Nrql struct {
+					Results []map[string]any `json:"results"`
+				} `json:"nrql"`
+			} `json:"account"`
+		} `json:"actor"`
+	}]{}
+	err := Fetch(gqlc, &graphql.NrqlQueryVariables{
+		AccountId: 1,
+		Query:     "SELECT count(*) FROM Log",
+	}, res)
+
+	assert.Nil(t, err)
+	assert.Equal(t, float64(42), res.Data.Actor.Account.Nrql.Results[0]["count"])
+}
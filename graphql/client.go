@@ -7,7 +7,6 @@ import (
 	"html/template"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -28,9 +27,24 @@ const (
 )
 
 type graphQlRequestPayload struct {
-	Query string `json:"query"`
+	Query     string `json:"query"`
+	Variables any    `json:"variables,omitempty"`
 }
 
+// QueryMode defines how the query variables are passed to NerdGraph.
+type QueryMode int
+
+const (
+	// TemplateQueryMode renders the variables into the query text with
+	// html/template. It is kept for existing trackers only since the
+	// values are HTML escaped and not validated by NerdGraph.
+	TemplateQueryMode QueryMode = iota
+
+	// VariablesQueryMode sends the variables as GraphQL variables
+	// which are declared with their types within the query.
+	VariablesQueryMode
+)
+
 type IGraphQlClient interface {
 	Execute(
 		queryVariables any,
@@ -44,21 +58,71 @@ type GraphQlClient struct {
 	NewrelicGraphQlEndpoint string
 	QueryTemplateName       string
 	QueryTemplate           string
+	QueryMode               QueryMode
+	Credentials             CredentialProvider
+}
+
+// Option configures the client created by NewGraphQlClient or
+// NewGraphQlClientWithVariables.
+type Option func(*GraphQlClient)
+
+// WithCredentials defines where the API key comes from. By default it
+// is read from the NEWRELIC_API_KEY environment variable.
+func WithCredentials(
+	credentials CredentialProvider,
+) Option {
+	return func(c *GraphQlClient) {
+		c.Credentials = credentials
+	}
 }
 
+// NewGraphQlClient creates a client in the legacy template mode, use
+// NewGraphQlClientWithVariables for new queries.
 func NewGraphQlClient(
 	logger logging.ILogger,
 	newrelicGraphQlEndpoint string,
 	queryTemplateName string,
 	queryTemplate string,
+	opts ...Option,
 ) *GraphQlClient {
-	return &GraphQlClient{
-		Logger:                  logger,
+	c := &GraphQlClient{
+		Logger: logger.With(map[string]string{
+			"tracker.package": "internal.graphql",
+		}),
 		HttpClient:              &http.Client{Timeout: time.Duration(30 * time.Second)},
 		NewrelicGraphQlEndpoint: newrelicGraphQlEndpoint,
 		QueryTemplateName:       queryTemplateName,
 		QueryTemplate:           queryTemplate,
+		QueryMode:               TemplateQueryMode,
+		Credentials:             EnvApiKey(DEFAULT_API_KEY_ENV),
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
+}
+
+// NewGraphQlClientWithVariables creates a client which sends the query
+// variables as GraphQL variables. The query declares them with their
+// types, for example:
+//
+//	query($accountId: Int!, $query: Nrql!) {
+//	  actor { account(id: $accountId) { nrql(query: $query) { results } } }
+//	}
+//
+// The variables are given to Execute as a map or as a struct with JSON
+// tags.
+func NewGraphQlClientWithVariables(
+	logger logging.ILogger,
+	newrelicGraphQlEndpoint string,
+	query string,
+	opts ...Option,
+) *GraphQlClient {
+	c := NewGraphQlClient(logger, newrelicGraphQlEndpoint, "", query, opts...)
+	c.QueryMode = VariablesQueryMode
+	return c
 }
 
 func (c *GraphQlClient) Execute(
@@ -66,14 +130,20 @@ func (c *GraphQlClient) Execute(
 	result any,
 ) error {
 
-	// Substitute variables within query
-	query, err := c.substituteTemplateQuery(queryVariables)
+	// Get API key, clients which are built as struct literals read it
+	// from the environment
+	credentials := c.Credentials
+	if credentials == nil {
+		credentials = EnvApiKey(DEFAULT_API_KEY_ENV)
+	}
+	apiKey, err := credentials.ApiKey()
 	if err != nil {
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_API_KEY_IS_NOT_AVAILABLE, err, nil)
 		return err
 	}
 
 	// Create payload
-	payload, err := c.createPayload(query)
+	payload, err := c.createRequestPayload(queryVariables)
 	if err != nil {
 		return err
 	}
@@ -85,28 +155,18 @@ func (c *GraphQlClient) Execute(
 		payload,
 	)
 	if err != nil {
-		c.Logger.LogWithFields(logrus.ErrorLevel, GRAPHQL_CREATING_HTTP_REQUEST_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_CREATING_HTTP_REQUEST_HAS_FAILED, err, nil)
 		return err
 	}
 
 	// Add headers
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Api-Key", os.Getenv("NEWRELIC_API_KEY"))
+	req.Header.Add("Api-Key", apiKey)
 
 	// Perform HTTP request
 	res, err := c.HttpClient.Do(req)
 	if err != nil {
-		c.Logger.LogWithFields(logrus.ErrorLevel, GRAPHQL_PERFORMING_HTTP_REQUEST_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_PERFORMING_HTTP_REQUEST_HAS_FAILED, err, nil)
 		return err
 	}
 	defer res.Body.Close()
@@ -114,40 +174,52 @@ func (c *GraphQlClient) Execute(
 	// Read HTTP response
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		c.Logger.LogWithFields(logrus.ErrorLevel, GRAPHQL_READING_HTTP_RESPONSE_BODY_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_READING_HTTP_RESPONSE_BODY_HAS_FAILED, err, nil)
 		return err
 	}
 
 	// Check if call was successful
 	if res.StatusCode != http.StatusOK {
-		c.Logger.LogWithFields(logrus.ErrorLevel, GRAPHQL_RESPONSE_HAS_RETURNED_NOT_OK_STATUS_CODE,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   GRAPHQL_RESPONSE_HAS_RETURNED_NOT_OK_STATUS_CODE,
-			})
-		return errors.New(GRAPHQL_RESPONSE_HAS_RETURNED_NOT_OK_STATUS_CODE)
+		err = errors.New(GRAPHQL_RESPONSE_HAS_RETURNED_NOT_OK_STATUS_CODE)
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_RESPONSE_HAS_RETURNED_NOT_OK_STATUS_CODE, err, nil)
+		return err
 	}
 
 	err = json.Unmarshal(body, result)
 	if err != nil {
-		c.Logger.LogWithFields(logrus.ErrorLevel, GRAPHQL_PARSING_HTTP_RESPONSE_BODY_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_PARSING_HTTP_RESPONSE_BODY_HAS_FAILED, err, nil)
 		return err
 	}
 
+	// Check if NerdGraph has returned errors, the partial data is
+	// parsed into the result nevertheless
+	if respErr := parseResponseErrors(body); respErr != nil {
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_RESPONSE_HAS_RETURNED_ERRORS, respErr, nil)
+		return respErr
+	}
+
 	return nil
 }
 
+func (c *GraphQlClient) createRequestPayload(
+	queryVariables any,
+) (
+	*bytes.Buffer,
+	error,
+) {
+	// Send variables along with the query
+	if c.QueryMode == VariablesQueryMode {
+		return c.createPayloadWithVariables(&c.QueryTemplate, queryVariables)
+	}
+
+	// Substitute variables within query
+	query, err := c.substituteTemplateQuery(queryVariables)
+	if err != nil {
+		return nil, err
+	}
+	return c.createPayload(query)
+}
+
 func (c *GraphQlClient) substituteTemplateQuery(
 	queryVariables any,
 ) (
@@ -155,37 +227,19 @@ func (c *GraphQlClient) substituteTemplateQuery(
 	error,
 ) {
 	// Parse query template
-	c.Logger.LogWithFields(logrus.DebugLevel, GRAPHQL_SUBSTITUTING_TEMPLATE_VARIABLES,
-		map[string]string{
-			"tracker.package": "internal.graphql",
-			"tracker.file":    "client.go",
-		})
+	c.Logger.LogWithFields(logrus.DebugLevel, GRAPHQL_SUBSTITUTING_TEMPLATE_VARIABLES, nil)
 	t, err := template.New(c.QueryTemplateName).Parse(c.QueryTemplate)
 	if err != nil {
-		c.Logger.LogWithFields(logrus.ErrorLevel, GRAPHQL_SUBSTITUTING_TEMPLATE_VARIABLES_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_SUBSTITUTING_TEMPLATE_VARIABLES_HAS_FAILED, err, nil)
 		return nil, err
 	}
 
 	// Write substituted query template into buffer
-	c.Logger.LogWithFields(logrus.DebugLevel, GRAPHQL_EXECUTING_REQUEST,
-		map[string]string{
-			"tracker.package": "internal.graphql",
-			"tracker.file":    "client.go",
-		})
+	c.Logger.LogWithFields(logrus.DebugLevel, GRAPHQL_EXECUTING_REQUEST, nil)
 	buf := new(bytes.Buffer)
 	err = t.Execute(buf, queryVariables)
 	if err != nil {
-		c.Logger.LogWithFields(logrus.ErrorLevel, GRAPHQL_SUBSTITUTING_TEMPLATE_VARIABLES_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_SUBSTITUTING_TEMPLATE_VARIABLES_HAS_FAILED, err, nil)
 		return nil, err
 	}
 
@@ -200,18 +254,24 @@ func (c *GraphQlClient) createPayload(
 	*bytes.Buffer,
 	error,
 ) {
+	return c.createPayloadWithVariables(query, nil)
+}
+
+func (c *GraphQlClient) createPayloadWithVariables(
+	query *string,
+	queryVariables any,
+) (
+	*bytes.Buffer,
+	error,
+) {
 
 	// Create JSON data
 	payload, err := json.Marshal(&graphQlRequestPayload{
-		Query: *query,
+		Query:     *query,
+		Variables: queryVariables,
 	})
 	if err != nil {
-		c.Logger.LogWithFields(logrus.DebugLevel, GRAPHQL_CREATING_PAYLOAD_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.graphql",
-				"tracker.file":    "client.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(c.Logger, logrus.DebugLevel, GRAPHQL_CREATING_PAYLOAD_HAS_FAILED, err, nil)
 		return nil, err
 	}
 	return bytes.NewBuffer(payload), nil
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	logging "github.com/utr1903/newrelic-tracker-internal/logging"
 )
 
 const queryTemplate = `
@@ -46,10 +48,22 @@ func (l *loggerMock) LogWithFields(
 	l.msgs = append(l.msgs, msg)
 }
 
+func (l *loggerMock) With(
+	attributes map[string]string,
+) logging.ILogger {
+	return l
+}
+
 func (l *loggerMock) Flush() error {
 	return nil
 }
 
+func TestMain(m *testing.M) {
+	// The clients read the API key from the environment by default
+	os.Setenv(DEFAULT_API_KEY_ENV, "apiKey")
+	os.Exit(m.Run())
+}
+
 type graphqlResponseMock struct {
 	Result string
 }
@@ -254,3 +268,169 @@ func Test_GraphQlRequestSucceeds(t *testing.T) {
 	assert.Equal(t, true, ok)
 	assert.Equal(t, "val", val)
 }
+
+const queryWithVariables = `
+query($accountId: Int!, $nrqlQuery: Nrql!) {
+  actor {
+    nrql(accounts: [$accountId], query: $nrqlQuery) {
+      results
+    }
+  }
+}
+`
+
+type queryVariablesWithTagsMock struct {
+	AccountId int64  `json:"accountId"`
+	NrqlQuery string `json:"nrqlQuery"`
+}
+
+func Test_GraphQlRequestWithVariablesSucceeds(t *testing.T) {
+	var payload map[string]any
+	newrelicGraphQlServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&payload)
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"key": "val"}`))
+		}))
+	defer newrelicGraphQlServerMock.Close()
+
+	logger := newLoggerMock()
+
+	res := map[string]string{}
+	gqlc := NewGraphQlClientWithVariables(
+		logger,
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+	err := gqlc.Execute(
+		&queryVariablesWithTagsMock{
+			AccountId: 12345,
+			NrqlQuery: `FROM Log SELECT count(*) WHERE message = "failed"`,
+		},
+		&res)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "val", res["key"])
+	assert.Equal(t, queryWithVariables, payload["query"])
+	assert.Equal(t, map[string]any{
+		"accountId": float64(12345),
+		"nrqlQuery": `FROM Log SELECT count(*) WHERE message = "failed"`,
+	}, payload["variables"])
+}
+
+func Test_GraphQlReturnsErrors(t *testing.T) {
+	newrelicGraphQlServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{
+				"data": {"actor": {"account": {"name": "account"}}},
+				"errors": [{
+					"message": "NRQL Syntax Error",
+					"path": ["actor", "account", "nrql"],
+					"extensions": {"errorClass": "INVALID_INPUT"}
+				}]
+			}`))
+		}))
+	defer newrelicGraphQlServerMock.Close()
+
+	logger := newLoggerMock()
+
+	res := map[string]any{}
+	gqlc := NewGraphQlClientWithVariables(
+		logger,
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+	err := gqlc.Execute(map[string]any{}, &res)
+
+	var respErr *ResponseError
+	assert.ErrorAs(t, err, &respErr)
+	assert.Contains(t, logger.msgs, GRAPHQL_RESPONSE_HAS_RETURNED_ERRORS)
+	assert.Equal(t, "NRQL Syntax Error", respErr.Errors[0].Message)
+	assert.Equal(t, "INVALID_INPUT", respErr.Errors[0].ErrorClass())
+	assert.Equal(t, []any{"actor", "account", "nrql"}, respErr.Errors[0].Path)
+
+	partial := struct {
+		Actor struct {
+			Account struct {
+				Name string `json:"name"`
+			} `json:"account"`
+		} `json:"actor"`
+	}{}
+	assert.Nil(t, respErr.UnmarshalData(&partial))
+	assert.Equal(t, "account", partial.Actor.Account.Name)
+	assert.NotNil(t, res["data"])
+}
+
+func Test_MissingApiKeyFailsFast(t *testing.T) {
+	logger := newLoggerMock()
+
+	res := map[string]string{}
+	gqlc := NewGraphQlClientWithVariables(
+		logger,
+		"::",
+		queryWithVariables,
+		WithCredentials(EnvApiKey("MISSING_API_KEY")),
+	)
+	err := gqlc.Execute(map[string]any{}, &res)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "MISSING_API_KEY")
+	assert.Contains(t, logger.msgs, GRAPHQL_API_KEY_IS_NOT_AVAILABLE)
+	assert.NotContains(t, logger.msgs, GRAPHQL_CREATING_HTTP_REQUEST_HAS_FAILED)
+}
+
+func Test_GraphQlRequestUsesProvidedApiKey(t *testing.T) {
+	var apiKey string
+	newrelicGraphQlServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			apiKey = r.Header.Get("Api-Key")
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{}`))
+		}))
+	defer newrelicGraphQlServerMock.Close()
+
+	logger := newLoggerMock()
+
+	res := map[string]string{}
+	gqlc := NewGraphQlClientWithVariables(
+		logger,
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+		WithCredentials(StaticApiKey("staticApiKey")),
+	)
+	err := gqlc.Execute(map[string]any{}, &res)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "staticApiKey", apiKey)
+}
+
+func Test_GraphQlClientBuiltAsLiteralReadsApiKeyFromEnvironment(t *testing.T) {
+	var apiKey string
+	newrelicGraphQlServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			apiKey = r.Header.Get("Api-Key")
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{}`))
+		}))
+	defer newrelicGraphQlServerMock.Close()
+
+	res := map[string]string{}
+	gqlc := &GraphQlClient{
+		Logger:                  newLoggerMock(),
+		HttpClient:              &http.Client{},
+		NewrelicGraphQlEndpoint: newrelicGraphQlServerMock.URL,
+		QueryTemplateName:       "test",
+		QueryTemplate:           queryTemplate,
+	}
+	err := gqlc.Execute(&queryVariablesMock{
+		AccountId: 12345,
+		NrqlQuery: "NRQL query",
+	}, &res)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "apiKey", apiKey)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_API_KEY_ENV = "NEWRELIC_API_KEY"
+
+	GRAPHQL_API_KEY_IS_NOT_AVAILABLE = "api key is not available"
+)
+
+// CredentialProvider provides the API key for the NerdGraph requests.
+// It is asked for the key with every request so that rotated keys are
+// picked up.
+type CredentialProvider interface {
+	ApiKey() (string, error)
+}
+
+// CredentialProviderFunc adapts a function to the CredentialProvider
+// interface, for example to read the key from a secret store.
+type CredentialProviderFunc func() (string, error)
+
+func (f CredentialProviderFunc) ApiKey() (string, error) {
+	return f()
+}
+
+// StaticApiKey always provides the given key.
+func StaticApiKey(
+	apiKey string,
+) CredentialProvider {
+	return CredentialProviderFunc(func() (string, error) {
+		if apiKey == "" {
+			return "", errors.New(GRAPHQL_API_KEY_IS_NOT_AVAILABLE)
+		}
+		return apiKey, nil
+	})
+}
+
+// EnvApiKey provides the key of the given environment variable.
+func EnvApiKey(
+	name string,
+) CredentialProvider {
+	return CredentialProviderFunc(func() (string, error) {
+		apiKey := os.Getenv(name)
+		if apiKey == "" {
+			return "", errors.New(GRAPHQL_API_KEY_IS_NOT_AVAILABLE + ": " + name + " is not set")
+		}
+		return apiKey, nil
+	})
+}
+
+// FileApiKey provides the key stored within the given file, like a
+// mounted Kubernetes secret. The file is read again whenever it has
+// been modified.
+func FileApiKey(
+	path string,
+) CredentialProvider {
+	return &fileApiKey{
+		path: path,
+	}
+}
+
+type fileApiKey struct {
+	mu      sync.Mutex
+	path    string
+	apiKey  string
+	modTime time.Time
+}
+
+func (f *fileApiKey) ApiKey() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", errors.New(GRAPHQL_API_KEY_IS_NOT_AVAILABLE + ": " + err.Error())
+	}
+
+	if f.apiKey == "" || !info.ModTime().Equal(f.modTime) {
+		content, err := os.ReadFile(f.path)
+		if err != nil {
+			return "", errors.New(GRAPHQL_API_KEY_IS_NOT_AVAILABLE + ": " + err.Error())
+		}
+
+		f.apiKey = strings.TrimSpace(string(content))
+		f.modTime = info.ModTime()
+	}
+
+	if f.apiKey == "" {
+		return "", errors.New(GRAPHQL_API_KEY_IS_NOT_AVAILABLE + ": " + f.path + " is empty")
+	}
+	return f.apiKey, nil
+}
@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StaticApiKeyFailsWhenEmpty(t *testing.T) {
+	_, err := StaticApiKey("").ApiKey()
+
+	assert.NotNil(t, err)
+}
+
+func Test_FileApiKeyIsReadAgainAfterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	os.WriteFile(path, []byte("first\n"), 0600)
+
+	p := FileApiKey(path)
+	apiKey, err := p.ApiKey()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "first", apiKey)
+
+	os.WriteFile(path, []byte("second\n"), 0600)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	apiKey, err = p.ApiKey()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "second", apiKey)
+}
+
+func Test_FileApiKeyFailsWhenMissing(t *testing.T) {
+	_, err := FileApiKey(filepath.Join(t.TempDir(), "missing")).ApiKey()
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const GRAPHQL_RESPONSE_HAS_RETURNED_ERRORS = "response has returned errors"
+
+// GraphQlErrorLocation points to the part of the query an error
+// refers to.
+type GraphQlErrorLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQlError is a single entry of the errors array which NerdGraph
+// returns for example for invalid NRQL or missing permissions.
+type GraphQlError struct {
+	Message    string                 `json:"message"`
+	Path       []any                  `json:"path,omitempty"`
+	Locations  []GraphQlErrorLocation `json:"locations,omitempty"`
+	Extensions map[string]any         `json:"extensions,omitempty"`
+}
+
+// ErrorClass returns the class NerdGraph assigns to the error within
+// its extensions, for example "INVALID_INPUT".
+func (e *GraphQlError) ErrorClass() string {
+	if class, ok := e.Extensions["errorClass"].(string); ok {
+		return class
+	}
+	return ""
+}
+
+// ResponseError is returned when NerdGraph responds with errors. The
+// data which could be resolved nevertheless is kept as partial data.
+type ResponseError struct {
+	Errors []GraphQlError
+	Data   json.RawMessage
+}
+
+func (e *ResponseError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		if class := err.ErrorClass(); class != "" {
+			msgs = append(msgs, fmt.Sprintf("%s (%s)", err.Message, class))
+		} else {
+			msgs = append(msgs, err.Message)
+		}
+	}
+	return GRAPHQL_RESPONSE_HAS_RETURNED_ERRORS + ": " + strings.Join(msgs, "; ")
+}
+
+// UnmarshalData parses the partial data of the response.
+func (e *ResponseError) UnmarshalData(
+	v any,
+) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+type graphQlResponseEnvelope struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []GraphQlError  `json:"errors"`
+}
+
+// parseResponseErrors extracts the errors of the response body, bodies
+// which cannot be parsed are treated as free of errors.
+func parseResponseErrors(
+	body []byte,
+) *ResponseError {
+	var envelope graphQlResponseEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil
+	}
+
+	if len(envelope.Errors) == 0 {
+		return nil
+	}
+
+	data := envelope.Data
+	if string(data) == "null" {
+		data = nil
+	}
+
+	return &ResponseError{
+		Errors: envelope.Errors,
+		Data:   data,
+	}
+}
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logging "github.com/utr1903/newrelic-tracker-internal/logging"
+)
+
+const (
+	GRAPHQL_POLLING_ASYNC_NRQL_QUERY               = "polling async nrql query"
+	GRAPHQL_ASYNC_NRQL_QUERY_HAS_MISSING_PROGRESS  = "async nrql query has returned no progress"
+	GRAPHQL_ASYNC_NRQL_QUERY_HAS_EXCEEDED_DEADLINE = "async nrql query has exceeded retry deadline"
+	GRAPHQL_PARSING_QUERY_VARIABLES_HAS_FAILED     = "parsing query variables has failed"
+)
+
+const asyncNrqlQuery = `query($accountId: Int!, $query: Nrql!) {
+  actor {
+    account(id: $accountId) {
+      nrql(query: $query, async: true) {
+        results
+        queryProgress { queryId completed retryAfter retryDeadline resultExpiration }
+      }
+    }
+  }
+}`
+
+const nrqlQueryProgressQuery = `query($accountId: Int!, $queryId: ID!) {
+  actor {
+    account(id: $accountId) {
+      nrqlQueryProgress(queryId: $queryId) {
+        results
+        queryProgress { queryId completed retryAfter retryDeadline resultExpiration }
+      }
+    }
+  }
+}`
+
+// NrqlQueryVariables are the variables AsyncNrqlClient expects.
+type NrqlQueryVariables struct {
+	AccountId int    `json:"accountId"`
+	Query     string `json:"query"`
+}
+
+// NrqlQueryProgress tells whether an async NRQL query has completed.
+// The durations are given in seconds.
+type NrqlQueryProgress struct {
+	QueryId          string `json:"queryId"`
+	Completed        bool   `json:"completed"`
+	RetryAfter       int    `json:"retryAfter"`
+	RetryDeadline    int    `json:"retryDeadline"`
+	ResultExpiration int    `json:"resultExpiration"`
+}
+
+type asyncNrqlResult struct {
+	Results       json.RawMessage    `json:"results"`
+	QueryProgress *NrqlQueryProgress `json:"queryProgress,omitempty"`
+}
+
+type asyncNrqlData struct {
+	Actor struct {
+		Account struct {
+			Nrql              *asyncNrqlResult `json:"nrql,omitempty"`
+			NrqlQueryProgress *asyncNrqlResult `json:"nrqlQueryProgress,omitempty"`
+		} `json:"account"`
+	} `json:"actor"`
+}
+
+// AsyncNrqlClient runs NRQL queries asynchronously so that they are not
+// bound to the synchronous timeout of NerdGraph. It polls the progress
+// of the query until it completes and writes the results into the
+// result of Execute in the shape of a synchronous query:
+//
+//	{"data": {"actor": {"account": {"nrql": {"results": [...]}}}}}
+//
+// It can therefore replace a GraphQlClient wherever an IGraphQlClient
+// is expected, for example within Fetch.
+type AsyncNrqlClient struct {
+	Logger   logging.ILogger
+	query    *GraphQlClient
+	progress *GraphQlClient
+	sleep    func(time.Duration)
+	now      func() time.Time
+}
+
+func NewAsyncNrqlClient(
+	logger logging.ILogger,
+	newrelicGraphQlEndpoint string,
+	opts ...Option,
+) *AsyncNrqlClient {
+	query := NewGraphQlClientWithVariables(logger, newrelicGraphQlEndpoint, asyncNrqlQuery, opts...)
+	return &AsyncNrqlClient{
+		Logger:   query.Logger,
+		query:    query,
+		progress: NewGraphQlClientWithVariables(logger, newrelicGraphQlEndpoint, nrqlQueryProgressQuery, opts...),
+		sleep:    time.Sleep,
+		now:      time.Now,
+	}
+}
+
+// Execute runs the query given by NrqlQueryVariables, or a map or
+// struct with the same JSON fields, and waits for its results.
+func (c *AsyncNrqlClient) Execute(
+	queryVariables any,
+	result any,
+) error {
+	vars, err := parseNrqlQueryVariables(queryVariables)
+	if err != nil {
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_PARSING_QUERY_VARIABLES_HAS_FAILED, err, nil)
+		return err
+	}
+
+	started := c.now()
+	res, err := ExecuteTyped[asyncNrqlData](c.query, vars)
+	if err != nil {
+		return err
+	}
+	current := res.Data.Actor.Account.Nrql
+
+	// Poll the progress until the query is completed
+	var deadline time.Time
+	for current != nil && current.QueryProgress != nil && !current.QueryProgress.Completed {
+		progress := current.QueryProgress
+		if deadline.IsZero() {
+			deadline = started.Add(time.Duration(progress.RetryDeadline) * time.Second)
+		}
+
+		wait := time.Duration(progress.RetryAfter) * time.Second
+		if wait < time.Second {
+			wait = time.Second
+		}
+		if c.now().Add(wait).After(deadline) {
+			err = errors.New(GRAPHQL_ASYNC_NRQL_QUERY_HAS_EXCEEDED_DEADLINE)
+			logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_ASYNC_NRQL_QUERY_HAS_EXCEEDED_DEADLINE, err, map[string]string{
+				"nrql.query.id": progress.QueryId,
+			})
+			return err
+		}
+		c.sleep(wait)
+
+		c.Logger.LogWithFields(logrus.DebugLevel, GRAPHQL_POLLING_ASYNC_NRQL_QUERY, map[string]string{
+			"nrql.query.id": progress.QueryId,
+		})
+		res, err = ExecuteTyped[asyncNrqlData](c.progress, map[string]any{
+			"accountId": vars.AccountId,
+			"queryId":   progress.QueryId,
+		})
+		if err != nil {
+			return err
+		}
+		current = res.Data.Actor.Account.NrqlQueryProgress
+	}
+
+	if current == nil {
+		err = errors.New(GRAPHQL_ASYNC_NRQL_QUERY_HAS_MISSING_PROGRESS)
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_ASYNC_NRQL_QUERY_HAS_MISSING_PROGRESS, err, nil)
+		return err
+	}
+
+	// Hand over the results in the shape of a synchronous query
+	data := asyncNrqlData{}
+	data.Actor.Account.Nrql = &asyncNrqlResult{
+		Results: current.Results,
+	}
+	body, err := json.Marshal(&Response[asyncNrqlData]{Data: data})
+	if err != nil {
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_PARSING_HTTP_RESPONSE_BODY_HAS_FAILED, err, nil)
+		return err
+	}
+
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		logging.LogError(c.Logger, logrus.ErrorLevel, GRAPHQL_PARSING_HTTP_RESPONSE_BODY_HAS_FAILED, err, nil)
+		return err
+	}
+	return nil
+}
+
+func parseNrqlQueryVariables(
+	queryVariables any,
+) (
+	*NrqlQueryVariables,
+	error,
+) {
+	if vars, ok := queryVariables.(*NrqlQueryVariables); ok {
+		return vars, nil
+	}
+
+	bytes, err := json.Marshal(queryVariables)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := &NrqlQueryVariables{}
+	if err := json.Unmarshal(bytes, vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nrqlResultsMock struct {
+	Data struct {
+		Actor struct {
+			Account struct {
+				Nrql struct {
+					Results []map[string]any `json:"results"`
+				} `json:"nrql"`
+			} `json:"account"`
+		} `json:"actor"`
+	} `json:"data"`
+}
+
+// newAsyncNrqlServerMock completes the query after the given amount
+// of polls and records the variables of each request
+func newAsyncNrqlServerMock(
+	polls int,
+	retryDeadline int,
+	requests *[]map[string]any,
+) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			payload := graphQlRequestPayload{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			*requests = append(*requests, payload.Variables.(map[string]any))
+
+			field := "nrqlQueryProgress"
+			if len(*requests) == 1 {
+				field = "nrql"
+			}
+
+			completed := len(*requests) > polls
+			results := "null"
+			if completed {
+				results = `[{"count": 42}]`
+			}
+
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"data": {"actor": {"account": {%q: {
+				"results": %s,
+				"queryProgress": {"queryId": "query-id", "completed": %t, "retryAfter": 2, "retryDeadline": %d}
+			}}}}}`, field, results, completed, retryDeadline)
+		}))
+}
+
+func newAsyncNrqlClientMock(
+	endpoint string,
+	waits *[]time.Duration,
+) *AsyncNrqlClient {
+	now := time.Now()
+	c := NewAsyncNrqlClient(newLoggerMock(), endpoint)
+	c.now = func() time.Time { return now }
+	c.sleep = func(d time.Duration) {
+		*waits = append(*waits, d)
+		now = now.Add(d)
+	}
+	return c
+}
+
+func Test_AsyncNrqlPollsUntilCompleted(t *testing.T) {
+	requests := []map[string]any{}
+	newrelicGraphQlServerMock := newAsyncNrqlServerMock(2, 60, &requests)
+	defer newrelicGraphQlServerMock.Close()
+
+	waits := []time.Duration{}
+	c := newAsyncNrqlClientMock(newrelicGraphQlServerMock.URL, &waits)
+
+	res := &nrqlResultsMock{}
+	err := c.Execute(&NrqlQueryVariables{
+		AccountId: 1,
+		Query:     "SELECT count(*) FROM Log",
+	}, res)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(requests))
+	assert.Equal(t, "SELECT count(*) FROM Log", requests[0]["query"])
+	assert.Equal(t, "query-id", requests[1]["queryId"])
+	assert.Equal(t, float64(1), requests[2]["accountId"])
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, waits)
+	assert.Equal(t, float64(42), res.Data.Actor.Account.Nrql.Results[0]["count"])
+}
+
+func Test_AsyncNrqlReturnsImmediateResults(t *testing.T) {
+	requests := []map[string]any{}
+	newrelicGraphQlServerMock := newAsyncNrqlServerMock(0, 60, &requests)
+	defer newrelicGraphQlServerMock.Close()
+
+	waits := []time.Duration{}
+	c := newAsyncNrqlClientMock(newrelicGraphQlServerMock.URL, &waits)
+
+	res := &nrqlResultsMock{}
+	err := c.Execute(map[string]any{
+		"accountId": 1,
+		"query":     "SELECT count(*) FROM Log",
+	}, res)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(requests))
+	assert.Equal(t, 0, len(waits))
+	assert.Equal(t, 1, len(res.Data.Actor.Account.Nrql.Results))
+}
+
+func Test_AsyncNrqlStopsAtRetryDeadline(t *testing.T) {
+	requests := []map[string]any{}
+	newrelicGraphQlServerMock := newAsyncNrqlServerMock(10, 5, &requests)
+	defer newrelicGraphQlServerMock.Close()
+
+	waits := []time.Duration{}
+	c := newAsyncNrqlClientMock(newrelicGraphQlServerMock.URL, &waits)
+
+	res := &nrqlResultsMock{}
+	err := c.Execute(&NrqlQueryVariables{
+		AccountId: 1,
+		Query:     "SELECT count(*) FROM Log",
+	}, res)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, GRAPHQL_ASYNC_NRQL_QUERY_HAS_EXCEEDED_DEADLINE, err.Error())
+	assert.Equal(t, 3, len(requests))
+}
@@ -0,0 +1,368 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	NRQL_SELECT_IS_MISSING              = "select is missing"
+	NRQL_FROM_IS_MISSING                = "from is missing"
+	NRQL_IDENTIFIER_IS_INVALID          = "identifier is invalid"
+	NRQL_OPERATOR_IS_NOT_SUPPORTED      = "operator is not supported"
+	NRQL_LITERAL_TYPE_IS_NOT_SUPPORTED  = "literal type is not supported"
+	NRQL_LITERAL_IS_NOT_A_FINITE_NUMBER = "literal is not a finite number"
+	NRQL_DURATION_MUST_BE_WHOLE_SECONDS = "duration must be whole seconds"
+	NRQL_VALUE_DOES_NOT_MATCH_OPERATOR  = "value does not match operator"
+	NRQL_LIMIT_MUST_NOT_BE_NEGATIVE     = "limit must not be negative"
+)
+
+var (
+	simpleIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+	nrqlKeywords = map[string]bool{
+		"AND": true, "AS": true, "AGO": true, "BY": true, "COMPARE": true,
+		"FACET": true, "FROM": true, "IN": true, "IS": true, "LIKE": true,
+		"LIMIT": true, "NOT": true, "NULL": true, "OFFSET": true, "OR": true,
+		"ORDER": true, "SELECT": true, "SINCE": true, "TIMESERIES": true,
+		"UNTIL": true, "WHERE": true, "WITH": true,
+	}
+
+	nrqlOperators = map[string]bool{
+		"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+		"LIKE": true, "NOT LIKE": true, "RLIKE": true, "IN": true, "NOT IN": true,
+	}
+
+	nrqlDurationUnits = []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"weeks", 7 * 24 * time.Hour},
+		{"days", 24 * time.Hour},
+		{"hours", time.Hour},
+		{"minutes", time.Minute},
+		{"seconds", time.Second},
+	}
+)
+
+// NrqlQuery builds NRQL queries with quoted identifiers and escaped
+// literals, for example:
+//
+//	query, err := Select("count(*)").
+//		From("Transaction").
+//		Where("appName", "=", appName).
+//		Facet("host").
+//		Since(time.Hour).
+//		Build()
+//
+// The select expressions are not escaped since they are functions,
+// use QuoteIdentifier for attribute names which come from the outside.
+// Send the query as a GraphQL variable of type Nrql! to keep it intact.
+type NrqlQuery struct {
+	selects    []string
+	from       []string
+	wheres     []string
+	facets     []string
+	since      string
+	until      string
+	timeseries string
+	limit      string
+	err        error
+}
+
+func Select(
+	expressions ...string,
+) *NrqlQuery {
+	return &NrqlQuery{
+		selects: expressions,
+	}
+}
+
+func (q *NrqlQuery) From(
+	eventTypes ...string,
+) *NrqlQuery {
+	for _, eventType := range eventTypes {
+		q.from = append(q.from, q.identifier(eventType))
+	}
+	return q
+}
+
+// Where adds a condition comparing the attribute with the value. The
+// conditions are combined with AND. IN and NOT IN expect a slice, the
+// other operators a single value. Use WhereIsNull to check for nulls.
+func (q *NrqlQuery) Where(
+	attribute string,
+	operator string,
+	value any,
+) *NrqlQuery {
+	operator = strings.ToUpper(strings.TrimSpace(operator))
+	if !nrqlOperators[operator] {
+		q.fail(fmt.Errorf("%s: %s", NRQL_OPERATOR_IS_NOT_SUPPORTED, operator))
+		return q
+	}
+
+	// Lists are only valid for IN and NOT IN, nulls are checked with
+	// WhereIsNull
+	isList := isNrqlList(value)
+	if operator == "IN" || operator == "NOT IN" {
+		if !isList {
+			q.fail(fmt.Errorf("%s: %s expects a list", NRQL_VALUE_DOES_NOT_MATCH_OPERATOR, operator))
+			return q
+		}
+		if reflect.ValueOf(value).Len() == 0 {
+			q.fail(fmt.Errorf("%s: %s expects a non-empty list", NRQL_VALUE_DOES_NOT_MATCH_OPERATOR, operator))
+			return q
+		}
+	} else if isList || value == nil {
+		q.fail(fmt.Errorf("%s: %s expects a single value", NRQL_VALUE_DOES_NOT_MATCH_OPERATOR, operator))
+		return q
+	}
+
+	literal, err := QuoteLiteral(value)
+	if err != nil {
+		q.fail(err)
+		return q
+	}
+
+	q.wheres = append(q.wheres, fmt.Sprintf("%s %s %s", q.identifier(attribute), operator, literal))
+	return q
+}
+
+// WhereIsNull adds a condition checking whether the attribute is
+// missing, or present when negated.
+func (q *NrqlQuery) WhereIsNull(
+	attribute string,
+	isNull bool,
+) *NrqlQuery {
+	condition := "IS NULL"
+	if !isNull {
+		condition = "IS NOT NULL"
+	}
+
+	q.wheres = append(q.wheres, q.identifier(attribute)+" "+condition)
+	return q
+}
+
+func (q *NrqlQuery) Facet(
+	attributes ...string,
+) *NrqlQuery {
+	for _, attribute := range attributes {
+		q.facets = append(q.facets, q.identifier(attribute))
+	}
+	return q
+}
+
+// Since sets the beginning of the time range relative to now.
+func (q *NrqlQuery) Since(
+	ago time.Duration,
+) *NrqlQuery {
+	q.since = "SINCE " + q.duration(ago) + " ago"
+	return q
+}
+
+// SinceTime sets the beginning of the time range to a point in time.
+func (q *NrqlQuery) SinceTime(
+	t time.Time,
+) *NrqlQuery {
+	q.since = "SINCE " + strconv.FormatInt(t.UnixMilli(), 10)
+	return q
+}
+
+// Until sets the end of the time range relative to now.
+func (q *NrqlQuery) Until(
+	ago time.Duration,
+) *NrqlQuery {
+	q.until = "UNTIL " + q.duration(ago) + " ago"
+	return q
+}
+
+// UntilTime sets the end of the time range to a point in time.
+func (q *NrqlQuery) UntilTime(
+	t time.Time,
+) *NrqlQuery {
+	q.until = "UNTIL " + strconv.FormatInt(t.UnixMilli(), 10)
+	return q
+}
+
+// Timeseries buckets the results by the given duration, a duration of
+// zero lets New Relic choose the buckets.
+func (q *NrqlQuery) Timeseries(
+	bucket time.Duration,
+) *NrqlQuery {
+	if bucket == 0 {
+		q.timeseries = "TIMESERIES AUTO"
+		return q
+	}
+
+	q.timeseries = "TIMESERIES " + q.duration(bucket)
+	return q
+}
+
+func (q *NrqlQuery) Limit(
+	limit int,
+) *NrqlQuery {
+	if limit < 0 {
+		q.fail(fmt.Errorf("%s: %d", NRQL_LIMIT_MUST_NOT_BE_NEGATIVE, limit))
+		return q
+	}
+	q.limit = "LIMIT " + strconv.Itoa(limit)
+	return q
+}
+
+// LimitMax returns as many results as New Relic allows.
+func (q *NrqlQuery) LimitMax() *NrqlQuery {
+	q.limit = "LIMIT MAX"
+	return q
+}
+
+// Build returns the query or the first error which occurred while it
+// was assembled.
+func (q *NrqlQuery) Build() (
+	string,
+	error,
+) {
+	if q.err != nil {
+		return "", q.err
+	}
+	if len(q.selects) == 0 {
+		return "", errors.New(NRQL_SELECT_IS_MISSING)
+	}
+	if len(q.from) == 0 {
+		return "", errors.New(NRQL_FROM_IS_MISSING)
+	}
+
+	parts := []string{
+		"SELECT " + strings.Join(q.selects, ", "),
+		"FROM " + strings.Join(q.from, ", "),
+	}
+	if len(q.wheres) > 0 {
+		parts = append(parts, "WHERE "+strings.Join(q.wheres, " AND "))
+	}
+	if len(q.facets) > 0 {
+		parts = append(parts, "FACET "+strings.Join(q.facets, ", "))
+	}
+	for _, part := range []string{q.since, q.until, q.timeseries, q.limit} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+func (q *NrqlQuery) identifier(
+	name string,
+) string {
+	quoted, err := QuoteIdentifier(name)
+	if err != nil {
+		q.fail(err)
+	}
+	return quoted
+}
+
+func (q *NrqlQuery) duration(
+	d time.Duration,
+) string {
+	if d <= 0 || d%time.Second != 0 {
+		q.fail(fmt.Errorf("%s: %s", NRQL_DURATION_MUST_BE_WHOLE_SECONDS, d))
+		return ""
+	}
+
+	for _, unit := range nrqlDurationUnits {
+		if d%unit.duration == 0 {
+			return fmt.Sprintf("%d %s", d/unit.duration, unit.name)
+		}
+	}
+	return ""
+}
+
+func (q *NrqlQuery) fail(
+	err error,
+) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+func isNrqlList(
+	value any,
+) bool {
+	if value == nil {
+		return false
+	}
+	kind := reflect.TypeOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+// QuoteIdentifier quotes attribute and event type names with backticks
+// unless they are simple names. Backticks cannot be escaped in NRQL and
+// are rejected therefore.
+func QuoteIdentifier(
+	name string,
+) (
+	string,
+	error,
+) {
+	if name == "" || strings.Contains(name, "`") {
+		return "", fmt.Errorf("%s: %q", NRQL_IDENTIFIER_IS_INVALID, name)
+	}
+
+	if simpleIdentifier.MatchString(name) && !nrqlKeywords[strings.ToUpper(name)] {
+		return name, nil
+	}
+	return "`" + name + "`", nil
+}
+
+// QuoteLiteral renders the value as NRQL literal. Strings are single
+// quoted and escaped, times are given in epoch milliseconds and slices
+// become lists.
+func QuoteLiteral(
+	value any,
+) (
+	string,
+	error,
+) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v)
+		return "'" + escaped + "'", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return strconv.FormatInt(v.UnixMilli(), 10), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("%s: %v", NRQL_LITERAL_IS_NOT_A_FINITE_NUMBER, f)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			item, err := QuoteLiteral(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+		}
+		return "(" + strings.Join(items, ", ") + ")", nil
+	}
+
+	return "", fmt.Errorf("%s: %T", NRQL_LITERAL_TYPE_IS_NOT_SUPPORTED, value)
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BuildNrqlQuery(t *testing.T) {
+	query, err := Select("count(*)", "average(duration)").
+		From("Transaction").
+		Where("appName", "=", "tracker").
+		Where("http.statusCode", "IN", []int{500, 503}).
+		Facet("host", "error message").
+		Since(2 * time.Hour).
+		Until(30 * time.Minute).
+		Timeseries(5 * time.Minute).
+		LimitMax().
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT count(*), average(duration) FROM Transaction "+
+		"WHERE appName = 'tracker' AND http.statusCode IN (500, 503) "+
+		"FACET host, `error message` "+
+		"SINCE 2 hours ago UNTIL 30 minutes ago TIMESERIES 5 minutes LIMIT MAX", query)
+}
+
+func Test_NrqlQueryEscapesLiterals(t *testing.T) {
+	query, err := Select("count(*)").
+		From("Log").
+		Where("message", "LIKE", `%it's a \ test%`).
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT count(*) FROM Log WHERE message LIKE '%it\'s a \\ test%'`, query)
+}
+
+func Test_NrqlQueryQuotesKeywords(t *testing.T) {
+	query, err := Select("count(*)").
+		From("Log").
+		WhereIsNull("from", false).
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT count(*) FROM Log WHERE `from` IS NOT NULL", query)
+}
+
+func Test_NrqlQueryRejectsInvalidInput(t *testing.T) {
+	_, err := Select("count(*)").From("Log").Where("a`b", "=", 1).Build()
+	assert.NotNil(t, err)
+
+	_, err = Select("count(*)").From("Log").Where("a", "; DROP", 1).Build()
+	assert.NotNil(t, err)
+
+	_, err = Select("count(*)").From("Log").Since(1500 * time.Millisecond).Build()
+	assert.NotNil(t, err)
+
+	_, err = Select("count(*)").From("Log").Where("a", "IN", "x").Build()
+	assert.ErrorContains(t, err, NRQL_VALUE_DOES_NOT_MATCH_OPERATOR)
+
+	_, err = Select("count(*)").From("Log").Where("a", "NOT IN", nil).Build()
+	assert.ErrorContains(t, err, NRQL_VALUE_DOES_NOT_MATCH_OPERATOR)
+
+	_, err = Select("count(*)").From("Log").Where("a", "=", []string{"x", "y"}).Build()
+	assert.ErrorContains(t, err, NRQL_VALUE_DOES_NOT_MATCH_OPERATOR)
+
+	_, err = Select("count(*)").From("Log").Where("a", "=", nil).Build()
+	assert.ErrorContains(t, err, NRQL_VALUE_DOES_NOT_MATCH_OPERATOR)
+
+	_, err = Select("count(*)").From("Log").Where("a", "IN", []string{}).Build()
+	assert.ErrorContains(t, err, NRQL_VALUE_DOES_NOT_MATCH_OPERATOR)
+
+	_, err = Select("count(*)").From("Log").Where("a", "NOT IN", []int{}).Build()
+	assert.ErrorContains(t, err, NRQL_VALUE_DOES_NOT_MATCH_OPERATOR)
+
+	_, err = Select("count(*)").From("Log").Where("a", "=", math.NaN()).Build()
+	assert.ErrorContains(t, err, NRQL_LITERAL_IS_NOT_A_FINITE_NUMBER)
+
+	_, err = Select("count(*)").From("Log").Where("a", ">", math.Inf(1)).Build()
+	assert.ErrorContains(t, err, NRQL_LITERAL_IS_NOT_A_FINITE_NUMBER)
+
+	_, err = Select("count(*)").From("Log").Where("a", "IN", []float64{1, math.Inf(-1)}).Build()
+	assert.ErrorContains(t, err, NRQL_LITERAL_IS_NOT_A_FINITE_NUMBER)
+
+	_, err = Select("count(*)").From("Log").Limit(-5).Build()
+	assert.ErrorContains(t, err, NRQL_LIMIT_MUST_NOT_BE_NEGATIVE)
+
+	_, err = Select("count(*)").Build()
+	assert.Equal(t, NRQL_FROM_IS_MISSING, err.Error())
+}
+
+func Test_NrqlQueryWithTimestamps(t *testing.T) {
+	since := time.UnixMilli(1700000000000)
+
+	query, err := Select("count(*)").
+		From("Log").
+		SinceTime(since).
+		UntilTime(since.Add(time.Hour)).
+		Timeseries(0).
+		Limit(10).
+		Build()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT count(*) FROM Log SINCE 1700000000000 UNTIL 1700003600000 TIMESERIES AUTO LIMIT 10", query)
+}
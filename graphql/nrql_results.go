@@ -0,0 +1,346 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	NRQL_OUTPUT_MUST_BE_POINTER_TO_SLICE = "output must be a pointer to a slice of structs"
+	NRQL_VALUE_COULD_NOT_BE_CONVERTED    = "value could not be converted"
+)
+
+// Keys which NerdGraph adds to the NRQL results besides the aggregates
+const (
+	nrqlFacetKey      = "facet"
+	nrqlBeginTimeKey  = "beginTimeSeconds"
+	nrqlEndTimeKey    = "endTimeSeconds"
+	nrqlComparisonKey = "comparison"
+)
+
+// NrqlRow is a single NRQL result. FACET queries fill the facets,
+// TIMESERIES queries the time window and COMPARE WITH queries the
+// comparison ("current" or "previous"). The aggregates are stored by
+// their alias, nested values like percentiles are flattened with dots,
+// e.g. "percentile.duration.95".
+type NrqlRow struct {
+	Facets     []string
+	BeginTime  time.Time
+	EndTime    time.Time
+	Comparison string
+	Values     map[string]any
+}
+
+// Float returns the aggregate with the given alias as a number.
+func (r *NrqlRow) Float(
+	alias string,
+) (
+	float64,
+	bool,
+) {
+	return toFloat(r.Values[alias])
+}
+
+// NrqlSeries groups the rows of a TIMESERIES query which belong to the
+// same facets and comparison.
+type NrqlSeries struct {
+	Facets     []string
+	Comparison string
+	Rows       []NrqlRow
+}
+
+// DecodeNrqlResults turns the loosely typed NRQL results into rows.
+func DecodeNrqlResults(
+	results []map[string]any,
+) []NrqlRow {
+	rows := make([]NrqlRow, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, decodeNrqlResult(result))
+	}
+	return rows
+}
+
+func decodeNrqlResult(
+	result map[string]any,
+) NrqlRow {
+	row := NrqlRow{
+		Values: make(map[string]any),
+	}
+
+	for key, val := range result {
+		switch key {
+		case nrqlFacetKey:
+			row.Facets = decodeFacets(val)
+		case nrqlBeginTimeKey:
+			row.BeginTime = decodeSeconds(val)
+		case nrqlEndTimeKey:
+			row.EndTime = decodeSeconds(val)
+		case nrqlComparisonKey:
+			row.Comparison = fmt.Sprintf("%v", val)
+		default:
+			flattenValue(row.Values, key, val)
+		}
+	}
+
+	return row
+}
+
+// decodeFacets handles single facets as well as the arrays of
+// multiple facets.
+func decodeFacets(
+	val any,
+) []string {
+	if vals, ok := val.([]any); ok {
+		facets := make([]string, 0, len(vals))
+		for _, v := range vals {
+			facets = append(facets, facetString(v))
+		}
+		return facets
+	}
+	return []string{facetString(val)}
+}
+
+func facetString(
+	val any,
+) string {
+	if val == nil {
+		return ""
+	}
+	if f, ok := toFloat(val); ok && f == math.Trunc(f) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+func decodeSeconds(
+	val any,
+) time.Time {
+	seconds, ok := toFloat(val)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(int64(seconds), 0).UTC()
+}
+
+func flattenValue(
+	values map[string]any,
+	key string,
+	val any,
+) {
+	nested, ok := val.(map[string]any)
+	if !ok {
+		values[key] = val
+		return
+	}
+
+	for k, v := range nested {
+		flattenValue(values, key+"."+k, v)
+	}
+}
+
+// GroupNrqlSeries groups the rows by their facets and comparison in
+// the order of their first appearance. The rows of each series are
+// sorted by their begin time.
+func GroupNrqlSeries(
+	rows []NrqlRow,
+) []NrqlSeries {
+	series := make([]NrqlSeries, 0)
+	index := make(map[string]int)
+
+	for _, row := range rows {
+		key := strings.Join(append([]string{row.Comparison}, row.Facets...), "\x00")
+
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, NrqlSeries{
+				Facets:     row.Facets,
+				Comparison: row.Comparison,
+			})
+		}
+		series[i].Rows = append(series[i].Rows, row)
+	}
+
+	for i := range series {
+		rows := series[i].Rows
+		sort.SliceStable(rows, func(a, b int) bool {
+			return rows[a].BeginTime.Before(rows[b].BeginTime)
+		})
+	}
+
+	return series
+}
+
+// UnmarshalNrqlRows maps the rows onto a slice of structs. The fields
+// are selected with the nrql tag which holds either the alias of an
+// aggregate or one of the special names:
+//
+//	facet      first facet as string or all facets as []string
+//	facet.N    facet at index N
+//	beginTime  begin of the time window
+//	endTime    end of the time window
+//	comparison "current" or "previous"
+//
+// For example:
+//
+//	type usage struct {
+//		AppName string    `nrql:"facet"`
+//		Begin   time.Time `nrql:"beginTime"`
+//		Count   int64     `nrql:"count"`
+//		P95     float64   `nrql:"percentile.duration.95"`
+//	}
+func UnmarshalNrqlRows(
+	rows []NrqlRow,
+	out any,
+) error {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Pointer || ptr.Elem().Kind() != reflect.Slice ||
+		ptr.Elem().Type().Elem().Kind() != reflect.Struct {
+		return errors.New(NRQL_OUTPUT_MUST_BE_POINTER_TO_SLICE)
+	}
+
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+
+	for _, row := range rows {
+		elem := reflect.New(elemType).Elem()
+
+		for i := 0; i < elemType.NumField(); i++ {
+			field := elemType.Field(i)
+			tag, ok := field.Tag.Lookup("nrql")
+			if !ok || !field.IsExported() {
+				continue
+			}
+
+			val, ok := row.lookup(tag)
+			if !ok {
+				continue
+			}
+
+			if err := setField(elem.Field(i), val); err != nil {
+				return fmt.Errorf("%s: %s: %w", field.Name, tag, err)
+			}
+		}
+
+		slice = reflect.Append(slice, elem)
+	}
+
+	ptr.Elem().Set(slice)
+	return nil
+}
+
+func (r *NrqlRow) lookup(
+	tag string,
+) (
+	any,
+	bool,
+) {
+	switch tag {
+	case "facet":
+		return r.Facets, len(r.Facets) > 0
+	case "beginTime":
+		return r.BeginTime, !r.BeginTime.IsZero()
+	case "endTime":
+		return r.EndTime, !r.EndTime.IsZero()
+	case "comparison":
+		return r.Comparison, r.Comparison != ""
+	}
+
+	if strings.HasPrefix(tag, "facet.") {
+		if i, err := strconv.Atoi(strings.TrimPrefix(tag, "facet.")); err == nil {
+			if i >= 0 && i < len(r.Facets) {
+				return r.Facets[i], true
+			}
+			return nil, false
+		}
+	}
+
+	val, ok := r.Values[tag]
+	return val, ok && val != nil
+}
+
+func setField(
+	field reflect.Value,
+	val any,
+) error {
+	// Facets fill either a single string or a string slice
+	if facets, ok := val.([]string); ok {
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString(facets[0])
+			return nil
+		case field.Type() == reflect.TypeOf(facets):
+			field.Set(reflect.ValueOf(facets))
+			return nil
+		}
+	}
+
+	if field.Kind() == reflect.Interface {
+		field.Set(reflect.ValueOf(val))
+		return nil
+	}
+
+	if t, ok := val.(time.Time); ok && field.Type() == reflect.TypeOf(t) {
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f, ok := toFloat(val); ok {
+			field.SetFloat(f)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f, ok := toFloat(val); ok {
+			field.SetInt(int64(f))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f, ok := toFloat(val); ok && f >= 0 {
+			field.SetUint(uint64(f))
+			return nil
+		}
+	case reflect.String:
+		field.SetString(fmt.Sprintf("%v", val))
+		return nil
+	case reflect.Bool:
+		if b, ok := val.(bool); ok {
+			field.SetBool(b)
+			return nil
+		}
+	}
+
+	return errors.New(NRQL_VALUE_COULD_NOT_BE_CONVERTED)
+}
+
+func toFloat(
+	val any,
+) (
+	float64,
+	bool,
+) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseNrqlResultsMock(
+	results string,
+) []map[string]any {
+	parsed := make([]map[string]any, 0)
+	json.Unmarshal([]byte(results), &parsed)
+	return parsed
+}
+
+func Test_DecodeFacetResults(t *testing.T) {
+	rows := DecodeNrqlResults(parseNrqlResultsMock(`[
+		{"facet": ["app", 200], "appName": "app", "count": 5, "percentile.duration": {"95": 1.5}}
+	]`))
+
+	assert.Equal(t, []string{"app", "200"}, rows[0].Facets)
+	assert.Equal(t, float64(5), rows[0].Values["count"])
+	assert.Equal(t, 1.5, rows[0].Values["percentile.duration.95"])
+	assert.NotContains(t, rows[0].Values, "facet")
+}
+
+func Test_DecodeTimeseriesComparisonResults(t *testing.T) {
+	rows := DecodeNrqlResults(parseNrqlResultsMock(`[
+		{"beginTimeSeconds": 120, "endTimeSeconds": 180, "comparison": "current", "count": 2},
+		{"beginTimeSeconds": 60, "endTimeSeconds": 120, "comparison": "current", "count": 1},
+		{"beginTimeSeconds": 60, "endTimeSeconds": 120, "comparison": "previous", "count": 3}
+	]`))
+
+	assert.Equal(t, time.Unix(120, 0).UTC(), rows[0].BeginTime)
+	assert.Equal(t, time.Unix(180, 0).UTC(), rows[0].EndTime)
+
+	series := GroupNrqlSeries(rows)
+
+	assert.Equal(t, 2, len(series))
+	assert.Equal(t, "current", series[0].Comparison)
+	assert.Equal(t, time.Unix(60, 0).UTC(), series[0].Rows[0].BeginTime)
+	assert.Equal(t, "previous", series[1].Comparison)
+
+	count, ok := series[1].Rows[0].Float("count")
+	assert.True(t, ok)
+	assert.Equal(t, float64(3), count)
+}
+
+type usageMock struct {
+	AppName  string    `nrql:"facet"`
+	Status   string    `nrql:"facet.1"`
+	Begin    time.Time `nrql:"beginTime"`
+	Count    int64     `nrql:"count"`
+	P95      float64   `nrql:"percentile.duration.95"`
+	Missing  float64   `nrql:"missing"`
+	Untagged string
+}
+
+func Test_UnmarshalNrqlRows(t *testing.T) {
+	rows := DecodeNrqlResults(parseNrqlResultsMock(`[
+		{"facet": ["app", "OK"], "beginTimeSeconds": 60, "count": 5, "percentile.duration": {"95": 1.5}}
+	]`))
+
+	usages := make([]usageMock, 0)
+	err := UnmarshalNrqlRows(rows, &usages)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []usageMock{{
+		AppName: "app",
+		Status:  "OK",
+		Begin:   time.Unix(60, 0).UTC(),
+		Count:   5,
+		P95:     1.5,
+	}}, usages)
+}
+
+func Test_UnmarshalNrqlRowsFailsForInvalidOutput(t *testing.T) {
+	rows := DecodeNrqlResults(parseNrqlResultsMock(`[{"count": 5}]`))
+
+	usages := make([]usageMock, 0)
+	err := UnmarshalNrqlRows(rows, usages)
+
+	assert.Equal(t, NRQL_OUTPUT_MUST_BE_POINTER_TO_SLICE, err.Error())
+}
+
+func Test_UnmarshalNrqlRowsFailsForIncompatibleValues(t *testing.T) {
+	rows := DecodeNrqlResults(parseNrqlResultsMock(`[{"count": "many"}]`))
+
+	usages := make([]usageMock, 0)
+	err := UnmarshalNrqlRows(rows, &usages)
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"errors"
+)
+
+const (
+	GRAPHQL_PAGINATION_HAS_REACHED_MAX_PAGES = "pagination has reached max pages"
+	GRAPHQL_PAGINATION_CURSOR_HAS_REPEATED   = "pagination cursor has repeated"
+
+	DEFAULT_CURSOR_VARIABLE = "cursor"
+	DEFAULT_MAX_PAGES       = 100
+)
+
+// ErrCursorRepeated is returned by Paginate when NerdGraph returns the
+// cursor of the previous page again, which would never end otherwise.
+var ErrCursorRepeated = errors.New(GRAPHQL_PAGINATION_CURSOR_HAS_REPEATED)
+
+// ErrMaxPagesReached is returned by Paginate when there are still more
+// pages after the max pages are fetched. The yielded items are complete
+// up to that point.
+var ErrMaxPagesReached = errors.New(GRAPHQL_PAGINATION_HAS_REACHED_MAX_PAGES)
+
+type pagination struct {
+	cursorVariable string
+	maxPages       int
+}
+
+// PaginationOption configures Paginate.
+type PaginationOption func(*pagination)
+
+// WithCursorVariable defines the name of the GraphQL variable which the
+// cursor is passed with. Defaults to cursor.
+func WithCursorVariable(
+	name string,
+) PaginationOption {
+	return func(p *pagination) {
+		p.cursorVariable = name
+	}
+}
+
+// WithMaxPages limits the amount of pages which are fetched. Zero or a
+// negative value removes the limit.
+func WithMaxPages(
+	maxPages int,
+) PaginationOption {
+	return func(p *pagination) {
+		p.maxPages = maxPages
+	}
+}
+
+// Paginate executes the query of the client page by page until the
+// next cursor is empty. The client must be in variables mode and the
+// query must declare the cursor variable, for example:
+//
+//	query($query: String!, $cursor: String) {
+//	  actor { entitySearch(query: $query) { results(cursor: $cursor) {
+//	    nextCursor entities { guid name }
+//	  } } }
+//	}
+//
+// The page function extracts the items and the next cursor from the
+// data of each response, yield is called for every item in order. An
+// error returned by yield stops the pagination and is returned as is.
+func Paginate[T any, I any](
+	c IGraphQlClient,
+	queryVariables map[string]any,
+	page func(data *T) ([]I, string),
+	yield func(item I) error,
+	opts ...PaginationOption,
+) error {
+	p := &pagination{
+		cursorVariable: DEFAULT_CURSOR_VARIABLE,
+		maxPages:       DEFAULT_MAX_PAGES,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	// Copy the variables not to change the ones of the caller
+	vars := make(map[string]any, len(queryVariables)+1)
+	for key, val := range queryVariables {
+		vars[key] = val
+	}
+
+	for pages := 0; ; pages++ {
+		if p.maxPages > 0 && pages >= p.maxPages {
+			return ErrMaxPagesReached
+		}
+
+		res, err := ExecuteTyped[T](c, vars)
+		if err != nil {
+			return err
+		}
+
+		items, nextCursor := page(&res.Data)
+		for _, item := range items {
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+
+		if nextCursor == "" {
+			return nil
+		}
+		if cursor, ok := vars[p.cursorVariable]; ok && cursor == nextCursor {
+			return ErrCursorRepeated
+		}
+		vars[p.cursorVariable] = nextCursor
+	}
+}
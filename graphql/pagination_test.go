@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type entitySearchMock struct {
+	Actor struct {
+		EntitySearch struct {
+			Results struct {
+				NextCursor string `json:"nextCursor"`
+				Entities   []struct {
+					Name string `json:"name"`
+				} `json:"entities"`
+			} `json:"results"`
+		} `json:"entitySearch"`
+	} `json:"actor"`
+}
+
+func entitySearchPage(
+	data *entitySearchMock,
+) (
+	[]string,
+	string,
+) {
+	names := []string{}
+	for _, entity := range data.Actor.EntitySearch.Results.Entities {
+		names = append(names, entity.Name)
+	}
+	return names, data.Actor.EntitySearch.Results.NextCursor
+}
+
+// newPaginatedServerMock serves the given amount of pages and records
+// the cursors it has received
+func newPaginatedServerMock(
+	pages int,
+	cursors *[]any,
+) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			payload := graphQlRequestPayload{}
+			json.NewDecoder(r.Body).Decode(&payload)
+
+			vars := payload.Variables.(map[string]any)
+			*cursors = append(*cursors, vars["cursor"])
+
+			page := len(*cursors)
+			nextCursor := ""
+			if page < pages {
+				nextCursor = fmt.Sprintf("cursor-%d", page)
+			}
+
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{"data": {"actor": {"entitySearch": {"results": {
+				"nextCursor": %q,
+				"entities": [{"name": "entity-%d-a"}, {"name": "entity-%d-b"}]
+			}}}}}`, nextCursor, page, page)
+		}))
+}
+
+func Test_PaginateFetchesAllPages(t *testing.T) {
+	cursors := []any{}
+	newrelicGraphQlServerMock := newPaginatedServerMock(3, &cursors)
+	defer newrelicGraphQlServerMock.Close()
+
+	gqlc := NewGraphQlClientWithVariables(
+		newLoggerMock(),
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+
+	names := []string{}
+	queryVariables := map[string]any{"query": "type = 'APPLICATION'"}
+	err := Paginate(gqlc, queryVariables, entitySearchPage,
+		func(name string) error {
+			names = append(names, name)
+			return nil
+		})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []any{nil, "cursor-1", "cursor-2"}, cursors)
+	assert.Equal(t, 6, len(names))
+	assert.Equal(t, "entity-3-b", names[5])
+	assert.NotContains(t, queryVariables, "cursor")
+}
+
+func Test_PaginateRespectsMaxPages(t *testing.T) {
+	cursors := []any{}
+	newrelicGraphQlServerMock := newPaginatedServerMock(5, &cursors)
+	defer newrelicGraphQlServerMock.Close()
+
+	gqlc := NewGraphQlClientWithVariables(
+		newLoggerMock(),
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+
+	names := []string{}
+	err := Paginate(gqlc, map[string]any{}, entitySearchPage,
+		func(name string) error {
+			names = append(names, name)
+			return nil
+		},
+		WithMaxPages(2))
+
+	assert.Equal(t, ErrMaxPagesReached, err)
+	assert.Equal(t, 2, len(cursors))
+	assert.Equal(t, 4, len(names))
+}
+
+func Test_PaginateStopsOnYieldError(t *testing.T) {
+	cursors := []any{}
+	newrelicGraphQlServerMock := newPaginatedServerMock(3, &cursors)
+	defer newrelicGraphQlServerMock.Close()
+
+	gqlc := NewGraphQlClientWithVariables(
+		newLoggerMock(),
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+
+	stop := errors.New("stop")
+	err := Paginate(gqlc, map[string]any{}, entitySearchPage,
+		func(name string) error {
+			return stop
+		})
+
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, len(cursors))
+}
+
+func Test_PaginateStopsOnRepeatedCursor(t *testing.T) {
+	requests := 0
+	newrelicGraphQlServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"data": {"actor": {"entitySearch": {"results": {
+				"nextCursor": "cursor",
+				"entities": [{"name": "entity"}]
+			}}}}}`))
+		}))
+	defer newrelicGraphQlServerMock.Close()
+
+	gqlc := NewGraphQlClientWithVariables(
+		newLoggerMock(),
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+
+	names := []string{}
+	err := Paginate(gqlc, map[string]any{}, entitySearchPage,
+		func(name string) error {
+			names = append(names, name)
+			return nil
+		},
+		WithMaxPages(0))
+
+	assert.Equal(t, ErrCursorRepeated, err)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 2, len(names))
+}
@@ -0,0 +1,39 @@
+package internal
+
+// Response is the envelope of a NerdGraph response. Callers declare
+// only the part of the data they are interested in, for example:
+//
+//	type nrqlData struct {
+//		Actor struct {
+//			Account struct {
+//				Nrql struct {
+//					Results []map[string]any `json:"results"`
+//				} `json:"nrql"`
+//			} `json:"account"`
+//		} `json:"actor"`
+//	}
+//
+//	res, err := ExecuteTyped[nrqlData](gqlc, queryVariables)
+type Response[T any] struct {
+	Data       T              `json:"data"`
+	Errors     []GraphQlError `json:"errors,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// ExecuteTyped executes the query of the client and parses the
+// response into the typed envelope. When NerdGraph returns errors, the
+// response is returned along with the *ResponseError so that the
+// partial data stays accessible.
+func ExecuteTyped[T any](
+	c IGraphQlClient,
+	queryVariables any,
+) (
+	*Response[T],
+	error,
+) {
+	res := &Response[T]{}
+	if err := c.Execute(queryVariables, res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type accountNameMock struct {
+	Actor struct {
+		Account struct {
+			Name string `json:"name"`
+		} `json:"account"`
+	} `json:"actor"`
+}
+
+func newGraphQlServerMock(
+	body string,
+) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(body))
+		}))
+}
+
+func Test_ExecuteTypedParsesData(t *testing.T) {
+	newrelicGraphQlServerMock := newGraphQlServerMock(`{
+		"data": {"actor": {"account": {"name": "account"}}},
+		"extensions": {"nrOnly": {"traceId": "trace"}}
+	}`)
+	defer newrelicGraphQlServerMock.Close()
+
+	gqlc := NewGraphQlClientWithVariables(
+		newLoggerMock(),
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+	res, err := ExecuteTyped[accountNameMock](gqlc, map[string]any{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "account", res.Data.Actor.Account.Name)
+	assert.Contains(t, res.Extensions, "nrOnly")
+}
+
+func Test_ExecuteTypedKeepsPartialData(t *testing.T) {
+	newrelicGraphQlServerMock := newGraphQlServerMock(`{
+		"data": {"actor": {"account": {"name": "account"}}},
+		"errors": [{"message": "error"}]
+	}`)
+	defer newrelicGraphQlServerMock.Close()
+
+	gqlc := NewGraphQlClientWithVariables(
+		newLoggerMock(),
+		newrelicGraphQlServerMock.URL,
+		queryWithVariables,
+	)
+	res, err := ExecuteTyped[accountNameMock](gqlc, map[string]any{})
+
+	var respErr *ResponseError
+	assert.ErrorAs(t, err, &respErr)
+	assert.Equal(t, "account", res.Data.Actor.Account.Name)
+	assert.Equal(t, "error", res.Errors[0].Message)
+}
@@ -0,0 +1,43 @@
+package internal
+
+import "runtime"
+
+const (
+	CODE_FUNCTION_ATTRIBUTE = "code.function"
+	CODE_FILEPATH_ATTRIBUTE = "code.filepath"
+	CODE_LINENO_ATTRIBUTE   = "code.lineno"
+)
+
+// caller returns the program counter of the function which called the
+// public logging method invoking caller.
+func (l *Logger) caller() uintptr {
+	if l.noCaller {
+		return 0
+	}
+
+	// Skip runtime.Callers, caller and the logging method
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(3+l.callerSkip, pcs) == 0 {
+		return 0
+	}
+	return pcs[0]
+}
+
+func callerAttributes(
+	pc uintptr,
+) map[string]interface{} {
+	if pc == 0 {
+		return nil
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.Function == "" {
+		return nil
+	}
+
+	return map[string]interface{}{
+		CODE_FUNCTION_ATTRIBUTE: frame.Function,
+		CODE_FILEPATH_ATTRIBUTE: frame.File,
+		CODE_LINENO_ATTRIBUTE:   frame.Line,
+	}
+}
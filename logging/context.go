@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TRACE_ID_ATTRIBUTE    = "trace.id"
+	SPAN_ID_ATTRIBUTE     = "span.id"
+	ENTITY_GUID_ATTRIBUTE = "entity.guid"
+	ENTITY_NAME_ATTRIBUTE = "entity.name"
+)
+
+// W3C trace context: version-traceid-parentid-flags
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+type traceparentKey struct{}
+
+// ContextWithTraceparent stores a W3C traceparent header value within
+// the context so that LogWithContext can correlate the logs with it.
+func ContextWithTraceparent(
+	ctx context.Context,
+	traceparent string,
+) context.Context {
+	return context.WithValue(ctx, traceparentKey{}, traceparent)
+}
+
+// ContextLogger is implemented by loggers which correlate their logs
+// with the trace within a context. It is kept apart from ILogger so
+// that own ILogger implementations do not need to implement it.
+type ContextLogger interface {
+	LogWithContext(
+		ctx context.Context,
+		lvl logrus.Level,
+		msg string,
+		attributes map[string]string,
+	)
+}
+
+// LogWithContext logs with the given logger and correlates the log with
+// the trace within the context. Loggers which do not implement
+// ContextLogger get the trace attributes along with the given ones.
+func LogWithContext(
+	logger ILogger,
+	ctx context.Context,
+	lvl logrus.Level,
+	msg string,
+	attributes map[string]string,
+) {
+	// Attribute the log to the caller of this function
+	if l, ok := logger.(*Logger); ok {
+		child := *l
+		child.callerSkip++
+		child.LogWithContext(ctx, lvl, msg, attributes)
+		return
+	}
+
+	if cl, ok := logger.(ContextLogger); ok {
+		cl.LogWithContext(ctx, lvl, msg, attributes)
+		return
+	}
+
+	attrs := traceAttributes(ctx)
+	for key, val := range attributes {
+		attrs[key] = val
+	}
+	logger.LogWithFields(lvl, msg, attrs)
+}
+
+// traceAttributes extracts the trace and span IDs from the context.
+// An OpenTelemetry span context takes precedence over a traceparent.
+func traceAttributes(
+	ctx context.Context,
+) map[string]string {
+	attrs := make(map[string]string)
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs[TRACE_ID_ATTRIBUTE] = sc.TraceID().String()
+		attrs[SPAN_ID_ATTRIBUTE] = sc.SpanID().String()
+		return attrs
+	}
+
+	traceparent, ok := ctx.Value(traceparentKey{}).(string)
+	if !ok {
+		return attrs
+	}
+
+	matches := traceparentPattern.FindStringSubmatch(traceparent)
+	if matches == nil {
+		return attrs
+	}
+
+	// All zero IDs are invalid according to the specification
+	if matches[1] == "00000000000000000000000000000000" ||
+		matches[2] == "0000000000000000" {
+		return attrs
+	}
+
+	attrs[TRACE_ID_ATTRIBUTE] = matches[1]
+	attrs[SPAN_ID_ATTRIBUTE] = matches[2]
+	return attrs
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_TraceAttributesFromTraceparent(t *testing.T) {
+	ctx := ContextWithTraceparent(context.Background(),
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	attrs := traceAttributes(ctx)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", attrs[TRACE_ID_ATTRIBUTE])
+	assert.Equal(t, "00f067aa0ba902b7", attrs[SPAN_ID_ATTRIBUTE])
+}
+
+func Test_TraceAttributesFromInvalidTraceparent(t *testing.T) {
+	ctx := ContextWithTraceparent(context.Background(),
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+
+	attrs := traceAttributes(ctx)
+
+	assert.Equal(t, 0, len(attrs))
+}
+
+func Test_TraceAttributesFromOpenTelemetrySpanContext(t *testing.T) {
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	attrs := traceAttributes(ctx)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", attrs[TRACE_ID_ATTRIBUTE])
+	assert.Equal(t, "00f067aa0ba902b7", attrs[SPAN_ID_ATTRIBUTE])
+}
+
+func Test_LogWithContextAttachesTraceAttributes(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+		WithEntity("entityGuid", "entityName"),
+	)
+
+	ctx := ContextWithTraceparent(context.Background(),
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	logger.LogWithContext(ctx, logrus.ErrorLevel, "msg", map[string]string{})
+
+	nrLogs := logger.forwarder.createNewRelicLogs(logger.forwarder.logs)
+
+	assert.Equal(t, "entityGuid", nrLogs[0].Common.Attributes[ENTITY_GUID_ATTRIBUTE])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", nrLogs[0].Logs[0].Attributes[TRACE_ID_ATTRIBUTE])
+	assert.Equal(t, "00f067aa0ba902b7", nrLogs[0].Logs[0].Attributes[SPAN_ID_ATTRIBUTE])
+}
+
+func Test_LogWithContextFallsBackToLogWithFields(t *testing.T) {
+	logger := &fieldsLoggerMock{}
+
+	ctx := ContextWithTraceparent(context.Background(),
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	LogWithContext(logger, ctx, logrus.ErrorLevel, "msg", map[string]string{
+		"tracker.job": "job",
+	})
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logger.attributes[TRACE_ID_ATTRIBUTE])
+	assert.Equal(t, "job", logger.attributes["tracker.job"])
+}
+
+// fieldsLoggerMock implements only ILogger like the loggers of library
+// consumers do
+type fieldsLoggerMock struct {
+	attributes map[string]string
+}
+
+func (l *fieldsLoggerMock) LogWithFields(
+	lvl logrus.Level,
+	msg string,
+	attributes map[string]string,
+) {
+	l.attributes = attributes
+}
+
+func (l *fieldsLoggerMock) With(
+	attributes map[string]string,
+) ILogger {
+	return l
+}
+
+func (l *fieldsLoggerMock) Flush() error {
+	return nil
+}
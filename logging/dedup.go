@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+const REPEAT_COUNT_ATTRIBUTE = "repeat.count"
+
+// deduplicate counts the log as a repetition when an identical one is
+// buffered which was first seen within the window. The caller must
+// hold the lock.
+func (f *forwarder) deduplicate(
+	log *bufferedLog,
+) bool {
+	if f.dedupWindow <= 0 {
+		return false
+	}
+
+	log.dedupKey = dedupKey(&log.entry)
+
+	first, ok := f.dedup[log.dedupKey]
+	if !ok || log.entry.Time.Sub(first.entry.Time) > f.dedupWindow {
+		return false
+	}
+
+	first.count++
+	return true
+}
+
+// remember registers the buffered log as the first occurrence of its
+// repetitions. The caller must hold the lock.
+func (f *forwarder) remember(
+	log *bufferedLog,
+) {
+	if log.dedupKey != 0 {
+		f.dedup[log.dedupKey] = log
+	}
+}
+
+// forget removes the log from the deduplication when it leaves the
+// buffer. The caller must hold the lock.
+func (f *forwarder) forget(
+	log *bufferedLog,
+) {
+	if f.dedup[log.dedupKey] == log {
+		delete(f.dedup, log.dedupKey)
+	}
+}
+
+// sample decides whether the log is kept according to the sampling
+// rate of its level. Levels without a rate are always kept.
+func (f *forwarder) sample(
+	log *bufferedLog,
+) bool {
+	rate, ok := f.samplingRates[log.entry.Level]
+	if !ok {
+		return true
+	}
+	return f.random() < rate
+}
+
+func (f *forwarder) sampledLogs() uint64 {
+	if f == nil {
+		return 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.sampled
+}
+
+// dedupKey hashes the level, the message and the attributes of the
+// entry. Attributes are sorted to make the key independent of the
+// map iteration order.
+func dedupKey(
+	e *logrus.Entry,
+) uint64 {
+	keys := make([]string, 0, len(e.Data))
+	for key := range e.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s", e.Level, e.Message)
+	for _, key := range keys {
+		fmt.Fprintf(h, "\x00%s=%v", key, e.Data[key])
+	}
+	return h.Sum64()
+}
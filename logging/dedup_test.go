@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEntryAt(
+	msg string,
+	t time.Time,
+) *logrus.Entry {
+	e := newTestEntry(msg)
+	e.Time = t
+	e.Level = logrus.ErrorLevel
+	return e
+}
+
+func Test_DeduplicationCollapsesRepetitions(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.dedupWindow = time.Minute
+
+	now := time.Now()
+	f.Fire(newTestEntryAt("failed", now))
+	f.Fire(newTestEntryAt("failed", now.Add(time.Second)))
+	f.Fire(newTestEntryAt("other", now.Add(time.Second)))
+	f.Fire(newTestEntryAt("failed", now.Add(2*time.Second)))
+
+	nrLogs := f.createNewRelicLogs(f.logs)
+
+	assert.Equal(t, 2, len(nrLogs[0].Logs))
+	assert.Equal(t, 3, nrLogs[0].Logs[0].Attributes[REPEAT_COUNT_ATTRIBUTE])
+	assert.NotContains(t, nrLogs[0].Logs[1].Attributes, REPEAT_COUNT_ATTRIBUTE)
+}
+
+func Test_DeduplicationStartsNewRecordAfterWindow(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.dedupWindow = time.Minute
+
+	now := time.Now()
+	f.Fire(newTestEntryAt("failed", now))
+	f.Fire(newTestEntryAt("failed", now.Add(2*time.Minute)))
+
+	assert.Equal(t, 2, len(f.logs))
+}
+
+func Test_DeduplicationDistinguishesAttributes(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.dedupWindow = time.Minute
+
+	now := time.Now()
+	first := newTestEntryAt("failed", now)
+	first.Data["account.id"] = "1"
+	second := newTestEntryAt("failed", now)
+	second.Data["account.id"] = "2"
+
+	f.Fire(first)
+	f.Fire(second)
+
+	assert.Equal(t, 2, len(f.logs))
+}
+
+func Test_SamplingDropsLogsOfLevel(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.samplingRates = map[logrus.Level]float64{
+		logrus.DebugLevel: 0.5,
+	}
+
+	randoms := []float64{0.2, 0.7}
+	f.random = func() float64 {
+		r := randoms[0]
+		randoms = randoms[1:]
+		return r
+	}
+
+	debug := newTestEntry("debug")
+	debug.Level = logrus.DebugLevel
+
+	f.Fire(debug)
+	f.Fire(debug)
+	f.Fire(newTestEntryAt("error", time.Now()))
+
+	assert.Equal(t, 2, len(f.logs))
+	assert.Equal(t, uint64(1), f.sampledLogs())
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ERROR_MESSAGE_ATTRIBUTE = "error.message"
+	ERROR_CLASS_ATTRIBUTE   = "error.class"
+	ERROR_STACK_ATTRIBUTE   = "error.stack"
+
+	// TRACKER_ERROR_ATTRIBUTE carries the error message as well, alerts
+	// and dashboards of the trackers filter on it
+	TRACKER_ERROR_ATTRIBUTE = "tracker.error"
+)
+
+// ErrorLogger is implemented by loggers which record errors with New
+// Relic error attributes. It is kept apart from ILogger so that own
+// ILogger implementations do not need to implement it.
+type ErrorLogger interface {
+	LogError(
+		lvl logrus.Level,
+		msg string,
+		err error,
+		attributes map[string]string,
+	)
+}
+
+// LogError logs the error with the given logger. Loggers which do not
+// implement ErrorLogger log the error message as attribute instead.
+func LogError(
+	logger ILogger,
+	lvl logrus.Level,
+	msg string,
+	err error,
+	attributes map[string]string,
+) {
+	// Attribute the log to the caller of this function
+	if l, ok := logger.(*Logger); ok {
+		child := *l
+		child.callerSkip++
+		child.LogError(lvl, msg, err, attributes)
+		return
+	}
+
+	if el, ok := logger.(ErrorLogger); ok {
+		el.LogError(lvl, msg, err, attributes)
+		return
+	}
+
+	attrs := make(map[string]string, len(attributes)+2)
+	if err != nil {
+		attrs[ERROR_MESSAGE_ATTRIBUTE] = err.Error()
+		attrs[TRACKER_ERROR_ATTRIBUTE] = err.Error()
+	}
+	for key, val := range attributes {
+		attrs[key] = val
+	}
+	logger.LogWithFields(lvl, msg, attrs)
+}
+
+// LogError logs the given error with its message, the Go type of its
+// root cause and a stack trace as New Relic error attributes.
+func (l *Logger) LogError(
+	lvl logrus.Level,
+	msg string,
+	err error,
+	attributes map[string]string,
+) {
+	attrs := make(map[string]string, len(attributes)+4)
+	for key, val := range errorAttributes(err, l.stack()) {
+		attrs[key] = val
+	}
+
+	// Given attributes take precedence over the error ones
+	for key, val := range attributes {
+		attrs[key] = val
+	}
+
+	l.logWithFields(context.Background(), l.caller(), lvl, msg, attrs)
+}
+
+// stack returns the stack trace of the function which called the
+// public logging method invoking stack.
+func (l *Logger) stack() string {
+	// Skip runtime.Callers, stack and the logging method
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3+l.callerSkip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// errorAttributes creates the error attributes. Errors which format
+// their own stack trace with %+v, like the ones of github.com/pkg/errors,
+// are preferred over the stack of the logging call.
+func errorAttributes(
+	err error,
+	callStack string,
+) map[string]string {
+	if err == nil {
+		return nil
+	}
+
+	attrs := map[string]string{
+		ERROR_MESSAGE_ATTRIBUTE: err.Error(),
+		TRACKER_ERROR_ATTRIBUTE: err.Error(),
+		ERROR_CLASS_ATTRIBUTE:   reflect.TypeOf(rootCause(err)).String(),
+		ERROR_STACK_ATTRIBUTE:   callStack,
+	}
+
+	for e := err; e != nil; e = unwrap(e) {
+		if _, ok := e.(fmt.Formatter); ok {
+			attrs[ERROR_STACK_ATTRIBUTE] = fmt.Sprintf("%+v", e)
+			break
+		}
+	}
+
+	return attrs
+}
+
+// rootCause unwraps the error until the innermost one is reached.
+func rootCause(
+	err error,
+) error {
+	for {
+		next := unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
+
+// unwrap follows the first error of joined errors as well.
+func unwrap(
+	err error,
+) error {
+	if next := errors.Unwrap(err); next != nil {
+		return next
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		if errs := joined.Unwrap(); len(errs) > 0 {
+			return errs[0]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type customError struct {
+	code int
+}
+
+func (e *customError) Error() string {
+	return fmt.Sprintf("custom error %d", e.code)
+}
+
+func Test_LogErrorRecordsErrorAttributes(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	err := fmt.Errorf("fetching failed: %w", &customError{code: 400})
+	logger.LogError(logrus.ErrorLevel, "msg", err, map[string]string{
+		"tracker.job": "job",
+	})
+
+	attrs := logger.forwarder.logs[0].entry.Data
+	assert.Equal(t, "fetching failed: custom error 400", attrs[ERROR_MESSAGE_ATTRIBUTE])
+	assert.Equal(t, "fetching failed: custom error 400", attrs[TRACKER_ERROR_ATTRIBUTE])
+	assert.Equal(t, "*internal.customError", attrs[ERROR_CLASS_ATTRIBUTE])
+	assert.Contains(t, attrs[ERROR_STACK_ATTRIBUTE], "Test_LogErrorRecordsErrorAttributes")
+	assert.Equal(t, "job", attrs["tracker.job"])
+}
+
+// joinedErrorMock unwraps like the errors joined by errors.Join
+type joinedErrorMock []error
+
+func (e joinedErrorMock) Error() string {
+	return "joined"
+}
+
+func (e joinedErrorMock) Unwrap() []error {
+	return e
+}
+
+func Test_RootCauseOfJoinedErrors(t *testing.T) {
+	cause := &customError{code: 500}
+	err := fmt.Errorf("wrapped: %w", joinedErrorMock{cause, fmt.Errorf("other")})
+
+	assert.Equal(t, cause, rootCause(err))
+}
+
+func Test_LogErrorAttributesCallerOfHelper(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	LogError(logger.With(nil), logrus.ErrorLevel, "msg", &customError{code: 400}, nil)
+
+	attrs := logger.forwarder.logs[0].entry.Data
+	assert.Equal(t, "custom error 400", attrs[ERROR_MESSAGE_ATTRIBUTE])
+	assert.Equal(t, "github.com/utr1903/newrelic-tracker-internal/logging.Test_LogErrorAttributesCallerOfHelper", attrs[CODE_FUNCTION_ATTRIBUTE])
+}
+
+func Test_LogErrorFallsBackToLogWithFields(t *testing.T) {
+	logger := &fieldsLoggerMock{}
+
+	LogError(logger, logrus.ErrorLevel, "msg", &customError{code: 400}, map[string]string{
+		"tracker.job": "job",
+	})
+
+	assert.Equal(t, "custom error 400", logger.attributes[ERROR_MESSAGE_ATTRIBUTE])
+	assert.Equal(t, "custom error 400", logger.attributes[TRACKER_ERROR_ATTRIBUTE])
+	assert.Equal(t, "job", logger.attributes["tracker.job"])
+}
@@ -3,14 +3,33 @@ package internal
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	enrichment "github.com/utr1903/newrelic-tracker-internal/enrichment"
+)
+
+const (
+	LOGTYPE_ATTRIBUTE      = "logtype"
+	SERVICE_NAME_ATTRIBUTE = "service.name"
+	HOSTNAME_ATTRIBUTE     = "hostname"
+
+	// Fields of a log block which attributes are renamed from by
+	// prefixing them
+	TIMESTAMP_FIELD          = "timestamp"
+	MESSAGE_FIELD            = "message"
+	LEVEL_FIELD              = "level"
+	RENAMED_ATTRIBUTE_PREFIX = "attribute."
+
+	INSTRUMENTATION_PROVIDER_ATTRIBUTE = "instrumentation.provider"
+	INSTRUMENTATION_PROVIDER           = "newrelic-tracker-internal"
 )
 
 type commonBlock struct {
@@ -18,9 +37,13 @@ type commonBlock struct {
 }
 
 type logBlock struct {
-	Timestamp  int64             `json:"timestamp"`
-	Message    string            `json:"message"`
-	Attributes map[string]string `json:"attributes"`
+	Timestamp  int64                  `json:"timestamp"`
+	Message    string                 `json:"message"`
+	Level      string                 `json:"level,omitempty"`
+	Logtype    string                 `json:"logtype,omitempty"`
+	Service    string                 `json:"service.name,omitempty"`
+	Hostname   string                 `json:"hostname,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 type logObject struct {
@@ -28,9 +51,78 @@ type logObject struct {
 	Logs   []logBlock   `json:"logs"`
 }
 
+// rawLogObject carries log blocks which are already marshalled while
+// the batches are created.
+type rawLogObject struct {
+	Common *commonBlock      `json:"common"`
+	Logs   []json.RawMessage `json:"logs"`
+}
+
+// DropPolicy decides what happens to a log entry which does not fit into
+// the buffer of the forwarder anymore.
+type DropPolicy int
+
+const (
+	// DropOldest removes the oldest buffered entries to make room.
+	DropOldest DropPolicy = iota
+
+	// DropNewest discards the incoming entry.
+	DropNewest
+
+	// FlushEarly forwards the buffered entries before the incoming one
+	// is added.
+	FlushEarly
+)
+
+const (
+	DEFAULT_BUFFER_MAX_ENTRIES = 10000
+	DEFAULT_BUFFER_MAX_BYTES   = 10 * 1024 * 1024
+	DEFAULT_FLUSH_INTERVAL     = 5 * time.Second
+
+	// Limits of the Log API
+	DEFAULT_MAX_MESSAGE_LENGTH   = 128 * 1024
+	DEFAULT_MAX_ATTRIBUTE_LENGTH = 4094
+	DEFAULT_MAX_PAYLOAD_BYTES    = 1000000
+)
+
+type bufferedLog struct {
+	entry    logrus.Entry
+	size     int
+	count    int
+	dedupKey uint64
+}
+
 type forwarder struct {
 	levels []logrus.Level
-	logs   []logrus.Entry
+
+	mu         sync.Mutex
+	logs       []*bufferedLog
+	logsSize   int
+	maxEntries int
+	maxBytes   int
+	dropPolicy DropPolicy
+	dropped    uint64
+
+	dedupWindow   time.Duration
+	dedup         map[uint64]*bufferedLog
+	samplingRates map[logrus.Level]float64
+	random        func() float64
+	sampled       uint64
+
+	async         bool
+	flushInterval time.Duration
+	batchSize     int
+	notify        chan struct{}
+	stop          chan struct{}
+	stopped       chan struct{}
+	stopOnce      sync.Once
+	closed        bool
+	failedFlushes uint64
+	lastFlushErr  error
+
+	maxMessageLength   int
+	maxAttributeLength int
+	maxPayloadBytes    int
 
 	client           *http.Client
 	licenseKey       string
@@ -45,12 +137,20 @@ func newForwarder(
 	commonAttributes map[string]string,
 ) *forwarder {
 	return &forwarder{
-		levels:           levels,
-		logs:             make([]logrus.Entry, 0),
-		client:           &http.Client{Timeout: time.Duration(30 * time.Second)},
-		licenseKey:       licenseKey,
-		logsEndpoint:     logsEndpoint,
-		commonAttributes: setCommonAttributes(commonAttributes),
+		levels:             levels,
+		logs:               make([]*bufferedLog, 0),
+		maxEntries:         DEFAULT_BUFFER_MAX_ENTRIES,
+		maxBytes:           DEFAULT_BUFFER_MAX_BYTES,
+		dropPolicy:         DropOldest,
+		dedup:              make(map[uint64]*bufferedLog),
+		random:             rand.Float64,
+		maxMessageLength:   DEFAULT_MAX_MESSAGE_LENGTH,
+		maxAttributeLength: DEFAULT_MAX_ATTRIBUTE_LENGTH,
+		maxPayloadBytes:    DEFAULT_MAX_PAYLOAD_BYTES,
+		client:             &http.Client{Timeout: time.Duration(30 * time.Second)},
+		licenseKey:         licenseKey,
+		logsEndpoint:       logsEndpoint,
+		commonAttributes:   setCommonAttributes(commonAttributes),
 	}
 }
 
@@ -64,85 +164,434 @@ func setCommonAttributes(
 		attrs[k] = v
 	}
 
+	// Kubernetes metadata
+	enrichment.Enrich(attrs, enrichment.NewKubernetesEnricher())
+
+	// Host and process metadata which the given attributes take
+	// precedence over
+	enrichment.EnrichMissing(attrs, enrichment.NewHostEnricher())
+
 	// Add the fixed attributes afterwards to avoid them
 	// being overridden by the given attributes
+	setFixedAttributes(attrs)
+
+	return attrs
+}
 
+// setFixedAttributes sets the attributes which neither the given
+// attributes nor any enricher may override.
+func setFixedAttributes(
+	attrs map[string]string,
+) {
 	// Instrumentation provider
-	attrs["instrumentation.provider"] = "newrelic-tracker-internal"
+	attrs[INSTRUMENTATION_PROVIDER_ATTRIBUTE] = INSTRUMENTATION_PROVIDER
+}
+
+func (f *forwarder) Levels() []logrus.Level {
+	return f.levels
+}
 
-	// Node name
-	if val := os.Getenv("NODE_NAME"); val != "" {
-		attrs["nodeName"] = val
+func (f *forwarder) Fire(e *logrus.Entry) error {
+	log := &bufferedLog{
+		entry: *e,
+		size:  estimateSize(e),
+		count: 1,
 	}
 
-	// Namespace name
-	if val := os.Getenv("NAMESPACE_NAME"); val != "" {
-		attrs["namespaceName"] = val
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Nothing ships the logs once the background routine is stopped
+	if f.closed {
+		f.dropped++
+		return nil
 	}
 
-	// Pod name
-	if val := os.Getenv("POD_NAME"); val != "" {
-		attrs["podName"] = val
+	// Collapse repetitions into the buffered log
+	if f.deduplicate(log) {
+		return nil
 	}
 
-	return attrs
+	if !f.sample(log) {
+		f.sampled++
+		return nil
+	}
+
+	if f.exceedsLimits(len(f.logs)+1, f.logsSize+log.size) {
+		switch f.dropPolicy {
+		case DropNewest:
+			f.dropped++
+			return nil
+		case FlushEarly:
+			// Let the background routine ship the buffer and make room
+			// by dropping the oldest entries in the meantime
+			if f.async {
+				f.notifyFlush()
+				break
+			}
+
+			batch := f.takeLogs()
+
+			// Do not hold the lock while talking to New Relic
+			f.mu.Unlock()
+			unsent, err := f.sendLogs(context.Background(), batch)
+			f.mu.Lock()
+
+			// The error is recorded instead of being returned since
+			// logrus would report it for every following log
+			if err != nil {
+				f.dropped += uint64(len(unsent))
+				f.recordFailedFlush(err)
+			}
+		}
+	}
+
+	f.append(log)
+
+	if f.async && f.batchSize > 0 && len(f.logs) >= f.batchSize {
+		f.notifyFlush()
+	}
+	return nil
 }
 
-func (f *forwarder) Levels() []logrus.Level {
-	return f.levels
+// append adds the given log to the buffer and drops the oldest
+// entries as long as the buffer exceeds its limits.
+func (f *forwarder) append(
+	log *bufferedLog,
+) {
+	f.logs = append(f.logs, log)
+	f.logsSize += log.size
+	f.remember(log)
+
+	drop := 0
+	for drop < len(f.logs)-1 && f.exceedsLimits(len(f.logs)-drop, f.logsSize) {
+		f.logsSize -= f.logs[drop].size
+		f.forget(f.logs[drop])
+		drop++
+	}
+
+	if drop > 0 {
+		f.logs = append(f.logs[:0:0], f.logs[drop:]...)
+		f.dropped += uint64(drop)
+	}
 }
 
-func (f *forwarder) Fire(e *logrus.Entry) error {
-	copy := *e
-	f.logs = append(f.logs, copy)
-	return nil
+// exceedsLimits checks whether a buffer with the given amount of
+// entries and bytes would exceed the configured limits.
+func (f *forwarder) exceedsLimits(
+	count int,
+	size int,
+) bool {
+	if f.maxEntries > 0 && count > f.maxEntries {
+		return true
+	}
+	if f.maxBytes > 0 && size > f.maxBytes {
+		return true
+	}
+	return false
+}
+
+// takeLogs empties the buffer and returns its previous content.
+// The caller must hold the lock.
+func (f *forwarder) takeLogs() []*bufferedLog {
+	logs := f.logs
+	f.logs = make([]*bufferedLog, 0, len(logs))
+	f.logsSize = 0
+	f.dedup = make(map[uint64]*bufferedLog)
+	return logs
+}
+
+// restoreLogs puts logs which could not be forwarded back in front of
+// the buffer so that they are retried with the next flush.
+func (f *forwarder) restoreLogs(
+	logs []*bufferedLog,
+) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current := f.logs
+	f.logs = make([]*bufferedLog, 0, len(logs)+len(current))
+	f.logsSize = 0
+	for _, log := range append(logs, current...) {
+		f.append(log)
+	}
+}
+
+// recordFailedFlush counts a flush which has failed in the background
+// or while logging. The caller must hold the lock.
+func (f *forwarder) recordFailedFlush(
+	err error,
+) {
+	f.failedFlushes++
+	f.lastFlushErr = err
+}
+
+func (f *forwarder) failedFlushesAndLastError() (
+	uint64,
+	error,
+) {
+	if f == nil {
+		return 0, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.failedFlushes, f.lastFlushErr
+}
+
+func (f *forwarder) droppedLogs() uint64 {
+	if f == nil {
+		return 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.dropped
+}
+
+// estimateSize approximates the bytes an entry occupies
+// within the payload.
+func estimateSize(
+	e *logrus.Entry,
+) int {
+	size := len(e.Message)
+	for key, val := range e.Data {
+		size += len(key) + len(fmt.Sprintf("%v", val))
+	}
+	return size
+}
+
+// start runs the background routine which ships the buffered logs
+// periodically and whenever a batch is filled.
+func (f *forwarder) start() {
+	f.notify = make(chan struct{}, 1)
+	f.stop = make(chan struct{})
+	f.stopped = make(chan struct{})
+
+	go f.run()
+}
+
+func (f *forwarder) run() {
+	defer close(f.stopped)
+
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-f.notify:
+		case <-f.stop:
+			return
+		}
+
+		// Failed logs are kept in the buffer and retried with the
+		// next flush, so the error is only recorded here
+		if err := f.flush(); err != nil {
+			f.mu.Lock()
+			f.recordFailedFlush(err)
+			f.mu.Unlock()
+		}
+	}
+}
+
+// notifyFlush signals the background routine to flush without
+// blocking the caller.
+func (f *forwarder) notifyFlush() {
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+}
+
+// close stops the background routine and drains the buffer.
+func (f *forwarder) close(
+	ctx context.Context,
+) error {
+	if f == nil {
+		return nil
+	}
+
+	if f.async {
+		f.mu.Lock()
+		f.closed = true
+		f.mu.Unlock()
+
+		f.stopOnce.Do(func() { close(f.stop) })
+
+		select {
+		case <-f.stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return f.flushWithContext(ctx)
 }
 
 func (f *forwarder) flush() error {
-	// Return if there are no logs
-	if len(f.logs) == 0 {
+	return f.flushWithContext(context.Background())
+}
+
+func (f *forwarder) flushWithContext(
+	ctx context.Context,
+) error {
+	if f == nil {
 		return nil
 	}
 
-	// Create New Relic logs
-	nrLogs := f.createNewRelicLogs()
+	f.mu.Lock()
+	logs := f.takeLogs()
+	f.mu.Unlock()
+
+	// Return if there are no logs
+	if len(logs) == 0 {
+		return nil
+	}
 
 	// Flush data to New Relic
-	return f.sendToNewRelic(nrLogs)
+	unsent, err := f.sendLogs(ctx, logs)
+	if err != nil {
+		f.restoreLogs(unsent)
+		return err
+	}
+
+	return nil
+}
+
+// sendLogs forwards the logs in batches which fit into the payload
+// limit and returns the logs which could not be forwarded.
+func (f *forwarder) sendLogs(
+	ctx context.Context,
+	logs []*bufferedLog,
+) (
+	[]*bufferedLog,
+	error,
+) {
+	batches := f.createBatches(logs)
+	for i, batch := range batches {
+		err := f.sendToNewRelic(ctx, []rawLogObject{{
+			Common: f.createCommonBlock(),
+			Logs:   batch.blocks,
+		}})
+		if err != nil {
+			unsent := make([]*bufferedLog, 0)
+			for _, b := range batches[i:] {
+				unsent = append(unsent, b.logs...)
+			}
+			return unsent, err
+		}
+	}
+	return nil, nil
 }
 
-func (f *forwarder) createNewRelicLogs() []logObject {
+func (f *forwarder) createNewRelicLogs(
+	logs []*bufferedLog,
+) []logObject {
 	lo := &logObject{
-		Common: &commonBlock{
-			Attributes: make(map[string]string),
-		},
-		Logs: make([]logBlock, 0, len(f.logs)),
+		Common: f.createCommonBlock(),
+		Logs:   make([]logBlock, 0, len(logs)),
+	}
+
+	// Create logs block
+	for _, log := range logs {
+		lo.Logs = append(lo.Logs, f.createLogBlock(log))
 	}
 
-	// Create common block
+	return []logObject{*lo}
+}
+
+func (f *forwarder) createCommonBlock() *commonBlock {
+	common := &commonBlock{
+		Attributes: make(map[string]string, len(f.commonAttributes)),
+	}
 	for key, val := range f.commonAttributes {
-		lo.Common.Attributes[key] = val
+		common.Attributes[key] = val
 	}
+	return common
+}
 
-	// Create logs block
-	for _, log := range f.logs {
-		logBlock := logBlock{
-			Timestamp:  log.Time.UnixMicro(),
-			Message:    log.Message,
-			Attributes: make(map[string]string),
+func (f *forwarder) createLogBlock(
+	log *bufferedLog,
+) logBlock {
+	message, truncated := truncate(log.entry.Message, f.maxMessageLength)
+
+	logBlock := logBlock{
+		Timestamp:  log.entry.Time.UnixMicro(),
+		Message:    message,
+		Level:      log.entry.Level.String(),
+		Attributes: make(map[string]interface{}),
+	}
+
+	for key, val := range log.entry.Data {
+
+		// Standard fields of a single log override the common ones
+		switch key {
+		case LOGTYPE_ATTRIBUTE, SERVICE_NAME_ATTRIBUTE, HOSTNAME_ATTRIBUTE:
+			field, cut := truncate(fmt.Sprintf("%v", val), f.maxAttributeLength)
+			truncated = truncated || cut
+
+			switch key {
+			case LOGTYPE_ATTRIBUTE:
+				logBlock.Logtype = field
+			case SERVICE_NAME_ATTRIBUTE:
+				logBlock.Service = field
+			case HOSTNAME_ATTRIBUTE:
+				logBlock.Hostname = field
+			}
+			continue
+
+		// Attributes must not collide with the fields of the log block
+		case TIMESTAMP_FIELD, MESSAGE_FIELD, LEVEL_FIELD:
+			key = RENAMED_ATTRIBUTE_PREFIX + key
 		}
 
-		for key, val := range log.Data {
-			logBlock.Attributes[key] = fmt.Sprintf("%v", val)
+		val := attributeValue(val)
+		if s, ok := val.(string); ok {
+			var cut bool
+			if val, cut = truncate(s, f.maxAttributeLength); cut {
+				truncated = true
+			}
 		}
-		lo.Logs = append(lo.Logs, logBlock)
+		logBlock.Attributes[key] = val
 	}
 
-	return []logObject{*lo}
+	if log.count > 1 {
+		logBlock.Attributes[REPEAT_COUNT_ATTRIBUTE] = log.count
+	}
+
+	if truncated {
+		logBlock.Attributes[TRUNCATED_ATTRIBUTE] = true
+	}
+
+	return logBlock
+}
+
+// attributeValue keeps the types which the Log API understands and
+// converts everything else into a string.
+func attributeValue(
+	val interface{},
+) interface{} {
+	switch v := val.(type) {
+	case string, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
 }
 
 func (f *forwarder) sendToNewRelic(
-	nrLogs []logObject,
+	ctx context.Context,
+	nrLogs []rawLogObject,
 ) error {
 
 	// Create zipped payload
@@ -152,7 +601,7 @@ func (f *forwarder) sendToNewRelic(
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest(http.MethodPost, f.logsEndpoint, payloadZipped)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.logsEndpoint, payloadZipped)
 	if err != nil {
 		return errors.New(LOGS_HTTP_REQUEST_COULD_NOT_BE_CREATED)
 	}
@@ -176,7 +625,7 @@ func (f *forwarder) sendToNewRelic(
 }
 
 func (f *forwarder) createPayload(
-	nrLogs []logObject,
+	nrLogs []rawLogObject,
 ) (
 	*bytes.Buffer,
 	error,
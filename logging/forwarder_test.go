@@ -0,0 +1,362 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	enrichment "github.com/utr1903/newrelic-tracker-internal/enrichment"
+)
+
+func newTestEntry(
+	msg string,
+) *logrus.Entry {
+	e := logrus.NewEntry(logrus.New())
+	e.Message = msg
+	return e
+}
+
+func newLogApiServerMock(
+	statusCode int,
+	requests *int32,
+) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(requests, 1)
+			w.WriteHeader(statusCode)
+		}))
+}
+
+func Test_BufferDropsOldestLogs(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.maxEntries = 2
+	f.dropPolicy = DropOldest
+
+	f.Fire(newTestEntry("first"))
+	f.Fire(newTestEntry("second"))
+	f.Fire(newTestEntry("third"))
+
+	assert.Equal(t, 2, len(f.logs))
+	assert.Equal(t, "second", f.logs[0].entry.Message)
+	assert.Equal(t, "third", f.logs[1].entry.Message)
+	assert.Equal(t, uint64(1), f.droppedLogs())
+}
+
+func Test_BufferDropsNewestLogs(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.maxEntries = 2
+	f.dropPolicy = DropNewest
+
+	f.Fire(newTestEntry("first"))
+	f.Fire(newTestEntry("second"))
+	f.Fire(newTestEntry("third"))
+
+	assert.Equal(t, 2, len(f.logs))
+	assert.Equal(t, "first", f.logs[0].entry.Message)
+	assert.Equal(t, "second", f.logs[1].entry.Message)
+	assert.Equal(t, uint64(1), f.droppedLogs())
+}
+
+func Test_BufferRespectsByteLimit(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.maxEntries = 0
+	f.maxBytes = 10
+
+	f.Fire(newTestEntry("12345"))
+	f.Fire(newTestEntry("67890"))
+	f.Fire(newTestEntry("abcde"))
+
+	assert.Equal(t, 2, len(f.logs))
+	assert.Equal(t, 10, f.logsSize)
+	assert.Equal(t, uint64(1), f.droppedLogs())
+}
+
+func Test_BufferFlushesEarly(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusAccepted, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	f := newForwarder(logrus.AllLevels, "licenseKey", newrelicLogApiServerMock.URL, map[string]string{})
+	f.maxEntries = 2
+	f.dropPolicy = FlushEarly
+
+	f.Fire(newTestEntry("first"))
+	f.Fire(newTestEntry("second"))
+	err := f.Fire(newTestEntry("third"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 1, len(f.logs))
+	assert.Equal(t, "third", f.logs[0].entry.Message)
+	assert.Equal(t, uint64(0), f.droppedLogs())
+}
+
+func Test_FailedEarlyFlushIsRecorded(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusInternalServerError, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	f := newForwarder(logrus.AllLevels, "licenseKey", newrelicLogApiServerMock.URL, map[string]string{})
+	f.maxEntries = 2
+	f.dropPolicy = FlushEarly
+
+	f.Fire(newTestEntry("first"))
+	f.Fire(newTestEntry("second"))
+	err := f.Fire(newTestEntry("third"))
+
+	failed, lastErr := f.failedFlushesAndLastError()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), failed)
+	assert.Equal(t, LOGS_NEW_RELIC_RETURNED_NOT_OK_STATUS, lastErr.Error())
+	assert.Equal(t, uint64(2), f.droppedLogs())
+	assert.Equal(t, "third", f.logs[0].entry.Message)
+}
+
+func Test_FlushEmptiesBuffer(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusAccepted, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	f := newForwarder(logrus.AllLevels, "licenseKey", newrelicLogApiServerMock.URL, map[string]string{})
+	f.Fire(newTestEntry("first"))
+
+	err := f.flush()
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 0, len(f.logs))
+}
+
+func Test_FailedFlushKeepsLogs(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusBadRequest, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	f := newForwarder(logrus.AllLevels, "licenseKey", newrelicLogApiServerMock.URL, map[string]string{})
+	f.Fire(newTestEntry("first"))
+
+	err := f.flush()
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(f.logs))
+	assert.Equal(t, 5, f.logsSize)
+}
+
+func Test_AsyncForwardingShipsFullBatch(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusAccepted, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		newrelicLogApiServerMock.URL,
+		map[string]string{},
+		WithAsyncForwarding(time.Hour, 2),
+	)
+	defer logger.Close(context.Background())
+
+	logger.LogWithFields(logrus.DebugLevel, "first", map[string]string{})
+	logger.LogWithFields(logrus.DebugLevel, "second", map[string]string{})
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&requests) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_AsyncForwardingRecordsFailedFlushes(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusInternalServerError, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		newrelicLogApiServerMock.URL,
+		map[string]string{},
+		WithAsyncForwarding(time.Hour, 1),
+	)
+	defer logger.Close(context.Background())
+
+	logger.LogWithFields(logrus.DebugLevel, "first", map[string]string{})
+
+	assert.Eventually(t, func() bool {
+		failed, _ := logger.FailedFlushes()
+		return failed == 1
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := logger.FailedFlushes()
+	assert.Equal(t, LOGS_NEW_RELIC_RETURNED_NOT_OK_STATUS, err.Error())
+}
+
+func Test_CloseDrainsBuffer(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusAccepted, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		newrelicLogApiServerMock.URL,
+		map[string]string{},
+		WithAsyncForwarding(time.Hour, 100),
+	)
+
+	logger.LogWithFields(logrus.DebugLevel, "first", map[string]string{})
+	err := logger.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 0, len(logger.forwarder.logs))
+}
+
+func Test_LogsAfterCloseAreDropped(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusAccepted, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		newrelicLogApiServerMock.URL,
+		map[string]string{},
+		WithAsyncForwarding(time.Hour, 100),
+		WithoutOutput(),
+	)
+
+	logger.LogWithFields(logrus.DebugLevel, "first", map[string]string{})
+	assert.Nil(t, logger.Close(context.Background()))
+
+	logger.LogWithFields(logrus.DebugLevel, "second", map[string]string{})
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.Equal(t, 0, len(logger.forwarder.logs))
+	assert.Equal(t, uint64(1), logger.DroppedLogs())
+}
+
+func Test_StandardFieldsAreForwarded(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+		WithLogtype("tracker"),
+		WithServiceName("usage-tracker"),
+		WithHostname("host"),
+	)
+
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{
+		LOGTYPE_ATTRIBUTE: "nerdgraph",
+	})
+
+	nrLogs := logger.forwarder.createNewRelicLogs(logger.forwarder.logs)
+	common := nrLogs[0].Common.Attributes
+	log := nrLogs[0].Logs[0]
+
+	assert.Equal(t, "tracker", common[LOGTYPE_ATTRIBUTE])
+	assert.Equal(t, "usage-tracker", common[SERVICE_NAME_ATTRIBUTE])
+	assert.Equal(t, "host", common[HOSTNAME_ATTRIBUTE])
+	assert.Equal(t, "error", log.Level)
+	assert.Equal(t, "nerdgraph", log.Logtype)
+	assert.NotContains(t, log.Attributes, LOGTYPE_ATTRIBUTE)
+}
+
+func Test_EnrichersDoNotOverrideFixedAttributes(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{
+			INSTRUMENTATION_PROVIDER_ATTRIBUTE: "given",
+		},
+		WithEnrichers(enrichment.EnricherFunc(func() map[string]string {
+			return map[string]string{
+				INSTRUMENTATION_PROVIDER_ATTRIBUTE: "enricher",
+				"tracker.team":                     "observability",
+			}
+		})),
+	)
+
+	common := logger.forwarder.commonAttributes
+
+	assert.Equal(t, INSTRUMENTATION_PROVIDER, common[INSTRUMENTATION_PROVIDER_ATTRIBUTE])
+	assert.Equal(t, "observability", common["tracker.team"])
+}
+
+func Test_StringAttributesStayStringsOnTheWire(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{
+		"account.id":   "12345",
+		"tracker.done": "true",
+	})
+	logger.write(context.Background(), 0, logrus.ErrorLevel, time.Time{}, "msg", logrus.Fields{
+		"query.count": 3,
+	})
+
+	payload, err := json.Marshal(logger.forwarder.createNewRelicLogs(logger.forwarder.logs))
+	assert.Nil(t, err)
+
+	// Attributes of LogWithFields are sent as strings as before, only
+	// typed attributes keep their types
+	assert.Contains(t, string(payload), `"account.id":"12345"`)
+	assert.Contains(t, string(payload), `"tracker.done":"true"`)
+	assert.Contains(t, string(payload), `"query.count":3`)
+}
+
+func Test_AttributesDoNotCollideWithStandardFields(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+		WithLimits(DEFAULT_MAX_MESSAGE_LENGTH, 4, DEFAULT_MAX_PAYLOAD_BYTES),
+	)
+
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{
+		"level":                "high",
+		SERVICE_NAME_ATTRIBUTE: "usage-tracker",
+	})
+
+	log := logger.forwarder.createNewRelicLogs(logger.forwarder.logs)[0].Logs[0]
+
+	assert.Equal(t, "error", log.Level)
+	assert.Equal(t, "high", log.Attributes[RENAMED_ATTRIBUTE_PREFIX+LEVEL_FIELD])
+	assert.NotContains(t, log.Attributes, LEVEL_FIELD)
+	assert.Equal(t, "usag", log.Service)
+	assert.Equal(t, true, log.Attributes[TRUNCATED_ATTRIBUTE])
+}
+
+func Test_GivenHostnameTakesPrecedence(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{
+			HOSTNAME_ATTRIBUTE: "given",
+		},
+	)
+	assert.Equal(t, "given", logger.forwarder.commonAttributes[HOSTNAME_ATTRIBUTE])
+
+	logger = NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{
+			HOSTNAME_ATTRIBUTE: "given",
+		},
+		WithHostname("option"),
+	)
+	assert.Equal(t, "option", logger.forwarder.commonAttributes[HOSTNAME_ATTRIBUTE])
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const LOGS_LEVEL_IS_UNKNOWN = "level is unknown"
+
+// levelControl is shared between a logger and its children so that a
+// level change applies to all of them.
+type levelControl struct {
+	mu     sync.Mutex
+	base   logrus.Level
+	revert *time.Timer
+}
+
+// parseLevel falls back to the error level for unknown levels, it is
+// used by the constructors only.
+func parseLevel(
+	logLevel string,
+) logrus.Level {
+	lvl, err := parseLevelStrict(logLevel)
+	if err != nil {
+		return logrus.ErrorLevel
+	}
+	return lvl
+}
+
+func parseLevelStrict(
+	logLevel string,
+) (
+	logrus.Level,
+	error,
+) {
+	switch strings.ToUpper(logLevel) {
+	case "DEBUG":
+		return logrus.DebugLevel, nil
+	case "INFO":
+		return logrus.InfoLevel, nil
+	case "WARN", "WARNING":
+		return logrus.WarnLevel, nil
+	case "ERROR":
+		return logrus.ErrorLevel, nil
+	default:
+		return logrus.ErrorLevel, fmt.Errorf("%s: %s", LOGS_LEVEL_IS_UNKNOWN, logLevel)
+	}
+}
+
+// Level returns the current level in the same notation as it is
+// given to NewLoggerWithForwarder.
+func (l *Logger) Level() string {
+	return strings.ToUpper(l.log.GetLevel().String())
+}
+
+// SetLevel changes the level permanently. Unknown levels are rejected.
+func (l *Logger) SetLevel(
+	logLevel string,
+) error {
+	lvl, err := parseLevelStrict(logLevel)
+	if err != nil {
+		return err
+	}
+
+	l.level.mu.Lock()
+	defer l.level.mu.Unlock()
+
+	l.level.stopRevert()
+	l.level.base = lvl
+	l.log.SetLevel(l.level.base)
+	return nil
+}
+
+// SetLevelFor changes the level temporarily. The logger falls back to
+// its previous permanent level after the given duration. Unknown
+// levels are rejected.
+func (l *Logger) SetLevelFor(
+	logLevel string,
+	duration time.Duration,
+) error {
+	lvl, err := parseLevelStrict(logLevel)
+	if err != nil {
+		return err
+	}
+
+	l.level.mu.Lock()
+	defer l.level.mu.Unlock()
+
+	l.level.stopRevert()
+	l.log.SetLevel(lvl)
+
+	var revert *time.Timer
+	revert = time.AfterFunc(duration, func() {
+		l.level.mu.Lock()
+		defer l.level.mu.Unlock()
+
+		// A later change has taken over in the meantime
+		if l.level.revert != revert {
+			return
+		}
+
+		l.level.revert = nil
+		l.log.SetLevel(l.level.base)
+	})
+	l.level.revert = revert
+	return nil
+}
+
+// toggleDebug switches to the debug level temporarily or back to the
+// permanent level when the logger is already on the debug level.
+func (l *Logger) toggleDebug(
+	duration time.Duration,
+) {
+	if l.log.GetLevel() == logrus.DebugLevel {
+		l.level.mu.Lock()
+		defer l.level.mu.Unlock()
+
+		l.level.stopRevert()
+		l.log.SetLevel(l.level.base)
+		return
+	}
+
+	l.SetLevelFor("DEBUG", duration)
+}
+
+// stopRevert cancels a pending fall back. The caller must hold the lock.
+func (lc *levelControl) stopRevert() {
+	if lc.revert != nil {
+		lc.revert.Stop()
+		lc.revert = nil
+	}
+}
+
+type levelRequest struct {
+	Level    string `json:"level"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// LevelHandler serves the level of the logger for an admin endpoint.
+// GET returns the current level, PUT changes it with a JSON body like
+// {"level": "DEBUG", "duration": "10m"}. Without a duration the change
+// is reverted after the given default duration, a duration of "0"
+// makes it permanent.
+func (l *Logger) LevelHandler(
+	defaultDuration time.Duration,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Level == "" {
+				http.Error(w, "invalid level request", http.StatusBadRequest)
+				return
+			}
+
+			duration := defaultDuration
+			if req.Duration != "" {
+				d, err := time.ParseDuration(req.Duration)
+				if err != nil {
+					http.Error(w, "invalid duration", http.StatusBadRequest)
+					return
+				}
+				duration = d
+			}
+
+			var err error
+			if duration > 0 {
+				err = l.SetLevelFor(req.Level, duration)
+			} else {
+				err = l.SetLevel(req.Level)
+			}
+			if err != nil {
+				http.Error(w, "invalid level", http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&levelRequest{
+			Level: l.Level(),
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SetLevelChangesChildLoggers(t *testing.T) {
+	logger := NewConsoleLogger("ERROR", WithoutOutput())
+	child := logger.With(map[string]string{}).(*Logger)
+
+	logger.SetLevel("DEBUG")
+
+	assert.Equal(t, "DEBUG", logger.Level())
+	assert.Equal(t, "DEBUG", child.Level())
+}
+
+func Test_SetLevelForRevertsAfterDuration(t *testing.T) {
+	logger := NewConsoleLogger("ERROR", WithoutOutput())
+
+	logger.SetLevelFor("DEBUG", 10*time.Millisecond)
+	assert.Equal(t, "DEBUG", logger.Level())
+
+	assert.Eventually(t, func() bool {
+		return logger.Level() == "ERROR"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func Test_SetLevelCancelsRevert(t *testing.T) {
+	logger := NewConsoleLogger("ERROR", WithoutOutput())
+
+	logger.SetLevelFor("DEBUG", 10*time.Millisecond)
+	logger.SetLevel("INFO")
+	time.Sleep(30 * time.Millisecond)
+
+	assert.Equal(t, "INFO", logger.Level())
+}
+
+func Test_LevelHandlerChangesLevel(t *testing.T) {
+	logger := NewConsoleLogger("ERROR", WithoutOutput())
+	h := logger.LevelHandler(time.Minute)
+
+	req := httptest.NewRequest(http.MethodPut, "/level",
+		strings.NewReader(`{"level": "DEBUG", "duration": "0"}`))
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"level": "DEBUG"}`, res.Body.String())
+	assert.Equal(t, "DEBUG", logger.Level())
+}
+
+func Test_LevelHandlerRejectsInvalidRequests(t *testing.T) {
+	logger := NewConsoleLogger("ERROR", WithoutOutput())
+	h := logger.LevelHandler(time.Minute)
+
+	req := httptest.NewRequest(http.MethodPut, "/level",
+		strings.NewReader(`{"level": "DEBUG", "duration": "soon"}`))
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "ERROR", logger.Level())
+}
+
+func Test_LevelHandlerRejectsUnknownLevels(t *testing.T) {
+	logger := NewConsoleLogger("INFO", WithoutOutput())
+	h := logger.LevelHandler(time.Minute)
+
+	for _, body := range []string{
+		`{"level": "degub"}`,
+		`{"level": "degub", "duration": "0"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(body))
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "INFO", logger.Level())
+	}
+}
+
+func Test_SetLevelRejectsUnknownLevels(t *testing.T) {
+	logger := NewConsoleLogger("degub", WithoutOutput())
+
+	err := logger.SetLevel("degub")
+
+	assert.ErrorContains(t, err, LOGS_LEVEL_IS_UNKNOWN)
+	assert.Equal(t, "ERROR", logger.Level())
+	assert.Nil(t, logger.SetLevel("warn"))
+	assert.Equal(t, "WARNING", logger.Level())
+}
+
+func Test_InfoLevelWritesInfoAndWarnLogs(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewConsoleLogger("INFO", WithOutput(out))
+
+	logger.LogWithFields(logrus.DebugLevel, "debug", nil)
+	logger.LogWithFields(logrus.InfoLevel, "info", nil)
+	logger.LogWithFields(logrus.WarnLevel, "warn", nil)
+	logger.LogWithFields(logrus.FatalLevel, "fatal", nil)
+
+	assert.NotContains(t, out.String(), `"msg":"debug"`)
+	assert.Contains(t, out.String(), `"level":"info","msg":"info"`)
+	assert.Contains(t, out.String(), `"level":"warning","msg":"warn"`)
+	assert.Contains(t, out.String(), `"level":"error","msg":"fatal"`)
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package internal
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ToggleDebugOnSignal switches the logger to the debug level for the
+// given duration whenever the process receives SIGUSR1. Another SIGUSR1
+// switches back right away. The returned function stops listening.
+func (l *Logger) ToggleDebugOnSignal(
+	duration time.Duration,
+) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				l.toggleDebug(duration)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
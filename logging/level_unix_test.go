@@ -0,0 +1,28 @@
+//go:build !windows
+
+package internal
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SignalTogglesDebugLevel(t *testing.T) {
+	logger := NewConsoleLogger("ERROR", WithoutOutput())
+
+	stop := logger.ToggleDebugOnSignal(time.Minute)
+	defer stop()
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	assert.Eventually(t, func() bool {
+		return logger.Level() == "DEBUG"
+	}, time.Second, 5*time.Millisecond)
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	assert.Eventually(t, func() bool {
+		return logger.Level() == "ERROR"
+	}, time.Second, 5*time.Millisecond)
+}
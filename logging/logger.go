@@ -1,7 +1,9 @@
 package internal
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -21,12 +23,21 @@ type ILogger interface {
 		attributes map[string]string,
 	)
 
+	With(
+		attributes map[string]string,
+	) ILogger
+
 	Flush() error
 }
 
 type Logger struct {
-	log       *logrus.Logger
-	forwarder *forwarder
+	log        *logrus.Logger
+	forwarder  *forwarder
+	redactor   *Redactor
+	fields     map[string]string
+	callerSkip int
+	noCaller   bool
+	level      *levelControl
 }
 
 func NewLoggerWithForwarder(
@@ -34,18 +45,8 @@ func NewLoggerWithForwarder(
 	licenseKey string,
 	logsEndpoint string,
 	commonAttributes map[string]string,
+	opts ...Option,
 ) *Logger {
-	l := logrus.New()
-	l.Out = os.Stdout
-	l.Formatter = &logrus.JSONFormatter{}
-
-	switch logLevel {
-	case "DEBUG":
-		l.Level = logrus.DebugLevel
-	default:
-		l.Level = logrus.ErrorLevel
-	}
-
 	f := newForwarder(
 		logrus.AllLevels,
 		licenseKey,
@@ -53,12 +54,56 @@ func NewLoggerWithForwarder(
 		commonAttributes,
 	)
 
-	l.AddHook(f)
+	logger := newLogger(logLevel, f, opts)
+
+	// Common attributes are final once all options are applied
+	f.commonAttributes = logger.redactor.RedactAttributes(f.commonAttributes)
+	f.truncateCommonAttributes()
+
+	if f.async {
+		f.start()
+	}
+
+	return logger
+}
+
+// NewConsoleLogger creates a logger which only writes to the output
+// and does not forward anything to New Relic. Options which configure
+// the forwarding are ignored.
+func NewConsoleLogger(
+	logLevel string,
+	opts ...Option,
+) *Logger {
+	return newLogger(logLevel, nil, opts)
+}
 
-	return &Logger{
+func newLogger(
+	logLevel string,
+	f *forwarder,
+	opts []Option,
+) *Logger {
+	l := logrus.New()
+	l.Out = os.Stdout
+	l.Formatter = &logrus.JSONFormatter{}
+	l.Level = parseLevel(logLevel)
+
+	if f != nil {
+		l.AddHook(f)
+	}
+
+	logger := &Logger{
 		log:       l,
 		forwarder: f,
+		level: &levelControl{
+			base: l.Level,
+		},
 	}
+
+	for _, opt := range opts {
+		opt(logger)
+	}
+
+	return logger
 }
 
 func (l *Logger) LogWithFields(
@@ -66,22 +111,144 @@ func (l *Logger) LogWithFields(
 	msg string,
 	attributes map[string]string,
 ) {
+	l.logWithFields(context.Background(), l.caller(), lvl, msg, attributes)
+}
 
-	fields := logrus.Fields{}
+// LogWithContext logs like LogWithFields and additionally correlates
+// the log with the trace within the given context.
+func (l *Logger) LogWithContext(
+	ctx context.Context,
+	lvl logrus.Level,
+	msg string,
+	attributes map[string]string,
+) {
+	attrs := make(map[string]string, len(attributes))
+	for key, val := range traceAttributes(ctx) {
+		attrs[key] = val
+	}
+
+	// Given attributes take precedence over the extracted ones
+	for key, val := range attributes {
+		attrs[key] = val
+	}
+
+	l.logWithFields(ctx, l.caller(), lvl, msg, attrs)
+}
 
-	// Put specific attributes
+func (l *Logger) logWithFields(
+	ctx context.Context,
+	pc uintptr,
+	lvl logrus.Level,
+	msg string,
+	attributes map[string]string,
+) {
+	fields := make(logrus.Fields, len(attributes))
 	for key, val := range attributes {
 		fields[key] = val
 	}
 
+	// Fatal and panic logs must not stop the tracker, trace logs are
+	// written as debug logs
 	switch lvl {
-	case logrus.ErrorLevel:
-		l.log.WithFields(fields).Error(msg)
-	default:
-		l.log.WithFields(fields).Debug(msg)
+	case logrus.PanicLevel, logrus.FatalLevel:
+		lvl = logrus.ErrorLevel
+	case logrus.TraceLevel:
+		lvl = logrus.DebugLevel
 	}
+
+	l.write(ctx, pc, lvl, time.Time{}, msg, fields)
+}
+
+// write is the single path through which all logs are written to the
+// output and handed over to the forwarder. A zero time is replaced
+// with the current one, a zero program counter omits the caller.
+func (l *Logger) write(
+	ctx context.Context,
+	pc uintptr,
+	lvl logrus.Level,
+	t time.Time,
+	msg string,
+	attributes logrus.Fields,
+) {
+	fields := make(logrus.Fields, len(l.fields)+len(attributes)+3)
+
+	// Put caller attributes
+	for key, val := range callerAttributes(pc) {
+		fields[key] = val
+	}
+
+	// Put bound attributes
+	for key, val := range l.fields {
+		fields[key] = l.redactor.redactValue(key, val)
+	}
+
+	// Put specific attributes which override the bound ones
+	for key, val := range attributes {
+		fields[key] = l.redactor.redactValue(key, val)
+	}
+
+	// Mask sensitive data before it reaches any output
+	msg = l.redactor.RedactString(msg)
+
+	entry := l.log.WithContext(ctx).WithFields(fields)
+	if !t.IsZero() {
+		entry = entry.WithTime(t)
+	}
+	entry.Log(lvl, msg)
+}
+
+// With returns a child logger which adds the given attributes to all
+// of its logs. The child shares the output and the forwarder of its
+// parent.
+func (l *Logger) With(
+	attributes map[string]string,
+) ILogger {
+	fields := make(map[string]string, len(l.fields)+len(attributes))
+	for key, val := range l.fields {
+		fields[key] = val
+	}
+	for key, val := range attributes {
+		fields[key] = val
+	}
+
+	child := *l
+	child.fields = fields
+	return &child
 }
 
 func (l *Logger) Flush() error {
 	return l.forwarder.flush()
 }
+
+// Close stops the background forwarding and ships the remaining logs
+// within the deadline of the given context. Logs which are written
+// afterwards are not forwarded anymore and counted as dropped.
+func (l *Logger) Close(
+	ctx context.Context,
+) error {
+	return l.forwarder.close(ctx)
+}
+
+// DroppedLogs returns the amount of log entries which were discarded
+// by the forwarder because its buffer was full.
+func (l *Logger) DroppedLogs() uint64 {
+	return l.forwarder.droppedLogs()
+}
+
+// SampledLogs returns the amount of log entries which were not
+// forwarded because of sampling.
+func (l *Logger) SampledLogs() uint64 {
+	return l.forwarder.sampledLogs()
+}
+
+// FailedFlushes returns the amount of flushes which have failed in the
+// background or because of the FlushEarly policy along with the error
+// of the last one. The logs of a failed background flush stay in the
+// buffer and are retried with the next one, the ones of a failed early
+// flush are counted as dropped.
+func (l *Logger) FailedFlushes() (
+	uint64,
+	error,
+) {
+	return l.forwarder.failedFlushesAndLastError()
+}
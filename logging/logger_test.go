@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ChildLoggerMergesBoundAttributes(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	child := logger.With(map[string]string{
+		"tracker.job": "job",
+		"account.id":  "12345",
+	})
+	grandchild := child.With(map[string]string{
+		"tracker.step": "step",
+	})
+
+	grandchild.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{
+		"account.id": "67890",
+	})
+
+	attrs := logger.forwarder.logs[0].entry.Data
+	assert.Equal(t, "job", attrs["tracker.job"])
+	assert.Equal(t, "step", attrs["tracker.step"])
+	assert.Equal(t, "67890", attrs["account.id"])
+}
+
+func Test_ChildLoggerDoesNotChangeParent(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	logger.With(map[string]string{
+		"tracker.job": "job",
+	})
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{})
+
+	_, ok := logger.forwarder.logs[0].entry.Data["tracker.job"]
+	assert.False(t, ok)
+}
+
+func Test_LoggerCapturesCaller(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	_, file, line, _ := runtime.Caller(0)
+	logger.With(map[string]string{}).LogWithFields(logrus.ErrorLevel, "msg", map[string]string{})
+
+	attrs := logger.forwarder.logs[0].entry.Data
+	assert.Equal(t, "github.com/utr1903/newrelic-tracker-internal/logging.Test_LoggerCapturesCaller", attrs[CODE_FUNCTION_ATTRIBUTE])
+	assert.Equal(t, file, attrs[CODE_FILEPATH_ATTRIBUTE])
+	assert.Equal(t, line+1, attrs[CODE_LINENO_ATTRIBUTE])
+}
+
+func Test_LoggerWithoutCaller(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+		WithoutCaller(),
+	)
+
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{})
+
+	_, ok := logger.forwarder.logs[0].entry.Data[CODE_FUNCTION_ATTRIBUTE]
+	assert.False(t, ok)
+}
+
+func Test_ConsoleLoggerDoesNotForward(t *testing.T) {
+	var out bytes.Buffer
+
+	logger := NewConsoleLogger(
+		"DEBUG",
+		WithOutput(&out),
+		WithAsyncForwarding(time.Second, 10),
+		WithEntity("entityGuid", "entityName"),
+	)
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{})
+
+	assert.Contains(t, out.String(), `"msg":"msg"`)
+	assert.Nil(t, logger.Flush())
+	assert.Nil(t, logger.Close(context.Background()))
+	assert.Equal(t, uint64(0), logger.DroppedLogs())
+}
+
+func Test_NoopLoggerSatisfiesInterface(t *testing.T) {
+	var logger ILogger = NewNoopLogger()
+
+	logger.With(map[string]string{}).LogWithFields(logrus.ErrorLevel, "msg", map[string]string{})
+	assert.Nil(t, logger.Flush())
+}
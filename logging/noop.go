@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NoopLogger discards all logs. It is meant for tests and for library
+// consumers which are not interested in the logs of the library.
+type NoopLogger struct{}
+
+func NewNoopLogger() *NoopLogger {
+	return &NoopLogger{}
+}
+
+func (l *NoopLogger) LogWithFields(
+	lvl logrus.Level,
+	msg string,
+	attributes map[string]string,
+) {
+}
+
+func (l *NoopLogger) LogWithContext(
+	ctx context.Context,
+	lvl logrus.Level,
+	msg string,
+	attributes map[string]string,
+) {
+}
+
+func (l *NoopLogger) LogError(
+	lvl logrus.Level,
+	msg string,
+	err error,
+	attributes map[string]string,
+) {
+}
+
+func (l *NoopLogger) With(
+	attributes map[string]string,
+) ILogger {
+	return l
+}
+
+func (l *NoopLogger) Flush() error {
+	return nil
+}
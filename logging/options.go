@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	enrichment "github.com/utr1903/newrelic-tracker-internal/enrichment"
+)
+
+// Option configures the logger created by NewLoggerWithForwarder or
+// NewConsoleLogger.
+type Option func(*Logger)
+
+// forwarderOption configures the forwarder of the logger and is
+// ignored by loggers without one.
+func forwarderOption(
+	opt func(f *forwarder),
+) Option {
+	return func(l *Logger) {
+		if l.forwarder != nil {
+			opt(l.forwarder)
+		}
+	}
+}
+
+// WithBufferLimits bounds the amount of log entries and bytes the
+// forwarder keeps in memory until the next flush. A limit of zero or
+// less disables the respective bound. Entries which do not fit are
+// handled according to the given policy.
+func WithBufferLimits(
+	maxEntries int,
+	maxBytes int,
+	policy DropPolicy,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		f.maxEntries = maxEntries
+		f.maxBytes = maxBytes
+		f.dropPolicy = policy
+	})
+}
+
+// WithAsyncForwarding ships the buffered logs in the background every
+// interval and as soon as the given amount of logs is buffered. Logging
+// does not block on New Relic anymore, call Close to drain the buffer
+// on shutdown.
+func WithAsyncForwarding(
+	flushInterval time.Duration,
+	batchSize int,
+) Option {
+	if flushInterval <= 0 {
+		flushInterval = DEFAULT_FLUSH_INTERVAL
+	}
+
+	return forwarderOption(func(f *forwarder) {
+		f.async = true
+		f.flushInterval = flushInterval
+		f.batchSize = batchSize
+	})
+}
+
+// WithRedaction masks sensitive data within the messages, the
+// attributes and the common attributes before they are written to
+// stdout and forwarded.
+func WithRedaction(
+	r *Redactor,
+) Option {
+	return func(l *Logger) {
+		l.redactor = r
+	}
+}
+
+// WithEntity attaches the GUID and the name of the New Relic entity
+// to all forwarded logs so that they show up in its logs in context.
+func WithEntity(
+	guid string,
+	name string,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		if guid != "" {
+			f.commonAttributes[ENTITY_GUID_ATTRIBUTE] = guid
+		}
+		if name != "" {
+			f.commonAttributes[ENTITY_NAME_ATTRIBUTE] = name
+		}
+	})
+}
+
+// WithCallerSkip skips the given amount of additional stack frames
+// when the caller of a log is determined. Wrappers around the logger
+// use it to report their callers instead of themselves.
+func WithCallerSkip(
+	skip int,
+) Option {
+	return func(l *Logger) {
+		l.callerSkip = skip
+	}
+}
+
+// WithoutCaller disables capturing the caller of the logs.
+func WithoutCaller() Option {
+	return func(l *Logger) {
+		l.noCaller = true
+	}
+}
+
+// WithEnrichers adds the attributes of the given enrichers to the
+// common block of the forwarded logs in addition to the Kubernetes,
+// host and process metadata.
+func WithEnrichers(
+	enrichers ...enrichment.Enricher,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		enrichment.Enrich(f.commonAttributes, enrichers...)
+		setFixedAttributes(f.commonAttributes)
+	})
+}
+
+// WithFormat defines how the logs are written to the output. The
+// forwarded logs are not affected.
+func WithFormat(
+	format Format,
+) Option {
+	return func(l *Logger) {
+		l.log.Formatter = newFormatter(format)
+	}
+}
+
+// WithFormatter writes the logs to the output with a custom formatter.
+func WithFormatter(
+	formatter logrus.Formatter,
+) Option {
+	return func(l *Logger) {
+		l.log.Formatter = formatter
+	}
+}
+
+// WithOutput writes the logs to the given writer instead of stdout,
+// for example os.Stderr or a RotatingFile.
+func WithOutput(
+	w io.Writer,
+) Option {
+	return func(l *Logger) {
+		l.log.Out = w
+	}
+}
+
+// WithoutOutput discards the logs locally so that they are only
+// forwarded to New Relic.
+func WithoutOutput() Option {
+	return WithOutput(io.Discard)
+}
+
+// WithDeduplication collapses identical logs, which are repeated within
+// the window after their first occurrence, into a single forwarded log
+// carrying the amount of repetitions as repeat.count.
+func WithDeduplication(
+	window time.Duration,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		f.dedupWindow = window
+	})
+}
+
+// WithSampling forwards only the given share, between 0 and 1, of the
+// logs of a level. Levels without a rate are forwarded completely.
+func WithSampling(
+	rates map[logrus.Level]float64,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		f.samplingRates = rates
+	})
+}
+
+// WithLimits overrides the limits of the Log API. Messages and string
+// attributes exceeding them are truncated and marked as such, logs
+// exceeding the payload limit are forwarded with multiple requests.
+// A limit of zero or less disables the respective limit.
+func WithLimits(
+	maxMessageLength int,
+	maxAttributeLength int,
+	maxPayloadBytes int,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		f.maxMessageLength = maxMessageLength
+		f.maxAttributeLength = maxAttributeLength
+		f.maxPayloadBytes = maxPayloadBytes
+	})
+}
+
+// WithLogtype sets the logtype of all forwarded logs which is used by
+// the parsing rules of New Relic.
+func WithLogtype(
+	logtype string,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		f.commonAttributes[LOGTYPE_ATTRIBUTE] = logtype
+	})
+}
+
+// WithServiceName sets the service name of all forwarded logs.
+func WithServiceName(
+	serviceName string,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		f.commonAttributes[SERVICE_NAME_ATTRIBUTE] = serviceName
+	})
+}
+
+// WithHostname overrides the hostname of all forwarded logs which is
+// detected by the host enricher otherwise.
+func WithHostname(
+	hostname string,
+) Option {
+	return forwarderOption(func(f *forwarder) {
+		f.commonAttributes[HOSTNAME_ATTRIBUTE] = hostname
+	})
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	OUTPUT_FILE_COULD_NOT_BE_OPENED  = "output file could not be opened"
+	OUTPUT_FILE_COULD_NOT_BE_ROTATED = "output file could not be rotated"
+)
+
+// Format defines how the logs are written to the output.
+type Format int
+
+const (
+	// FormatJSON writes one JSON object per log.
+	FormatJSON Format = iota
+
+	// FormatText writes human readable lines which are colored on
+	// terminals.
+	FormatText
+
+	// FormatLogfmt writes key=value pairs.
+	FormatLogfmt
+)
+
+func newFormatter(
+	format Format,
+) logrus.Formatter {
+	switch format {
+	case FormatText:
+		return &logrus.TextFormatter{
+			FullTimestamp: true,
+		}
+	case FormatLogfmt:
+		return &logrus.TextFormatter{
+			DisableColors: true,
+			FullTimestamp: true,
+		}
+	default:
+		return &logrus.JSONFormatter{}
+	}
+}
+
+// RotatingFile is an output which rotates the file when it exceeds the
+// maximum size. The rotated files are suffixed with .1, .2 and so on,
+// the oldest ones beyond the maximum amount of backups are removed.
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func NewRotatingFile(
+	path string,
+	maxBytes int64,
+	maxBackups int,
+) (
+	*RotatingFile,
+	error,
+) {
+	rf := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) Write(
+	p []byte,
+) (
+	int,
+	error,
+) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	var rotateErr error
+	if rf.maxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		rotateErr = rf.rotate()
+
+		// The current file could not be reopened either
+		if rf.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	// A failed rotation is reported while the log is still written
+	// into the current file
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, rotateErr
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	return rf.file.Close()
+}
+
+func (rf *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(rf.path), 0755); err != nil {
+		return errors.New(OUTPUT_FILE_COULD_NOT_BE_OPENED)
+	}
+
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.New(OUTPUT_FILE_COULD_NOT_BE_OPENED)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.New(OUTPUT_FILE_COULD_NOT_BE_OPENED)
+	}
+
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file aside and opens a new one. When the
+// file cannot be moved, the current one is reopened so that the logs
+// are still written. The file is nil only if it cannot be opened at all.
+func (rf *RotatingFile) rotate() error {
+	rf.file.Close()
+	rf.file = nil
+
+	if err := rf.shift(); err != nil {
+		if openErr := rf.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+
+	return rf.open()
+}
+
+// shift moves the backups one further, drops the oldest one and moves
+// the current file to the first backup.
+func (rf *RotatingFile) shift() error {
+	if rf.maxBackups > 0 {
+		os.Remove(rf.backup(rf.maxBackups))
+		for i := rf.maxBackups - 1; i > 0; i-- {
+			os.Rename(rf.backup(i), rf.backup(i+1))
+		}
+		if err := os.Rename(rf.path, rf.backup(1)); err != nil {
+			return errors.New(OUTPUT_FILE_COULD_NOT_BE_ROTATED)
+		}
+	} else if err := os.Remove(rf.path); err != nil {
+		return errors.New(OUTPUT_FILE_COULD_NOT_BE_ROTATED)
+	}
+	return nil
+}
+
+func (rf *RotatingFile) backup(
+	i int,
+) string {
+	return fmt.Sprintf("%s.%d", rf.path, i)
+}
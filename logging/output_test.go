@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoggerWritesLogfmtToOutput(t *testing.T) {
+	var out bytes.Buffer
+
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+		WithFormat(FormatLogfmt),
+		WithOutput(&out),
+	)
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{
+		"key": "val",
+	})
+
+	assert.Contains(t, out.String(), "level=error msg=msg")
+	assert.Contains(t, out.String(), "key=val")
+}
+
+func Test_LoggerWithoutOutputStillForwards(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+		WithoutOutput(),
+	)
+	logger.LogWithFields(logrus.ErrorLevel, "msg", map[string]string{})
+
+	assert.Equal(t, 1, len(logger.forwarder.logs))
+}
+
+func Test_RotatingFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tracker.log")
+
+	rf, err := NewRotatingFile(path, 10, 1)
+	assert.Nil(t, err)
+	defer rf.Close()
+
+	rf.Write([]byte("first....\n"))
+	rf.Write([]byte("second...\n"))
+	rf.Write([]byte("third....\n"))
+
+	current, _ := os.ReadFile(path)
+	backup, _ := os.ReadFile(path + ".1")
+	_, err = os.Stat(path + ".2")
+
+	assert.Equal(t, "third....\n", string(current))
+	assert.Equal(t, "second...\n", string(backup))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_RotatingFileKeepsWritingAfterFailedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tracker.log")
+
+	// A non-empty directory in place of the backup cannot be replaced
+	os.MkdirAll(filepath.Join(path+".1", "blocked"), 0755)
+
+	rf, err := NewRotatingFile(path, 10, 1)
+	assert.Nil(t, err)
+	defer rf.Close()
+
+	rf.Write([]byte("first....\n"))
+	n, err := rf.Write([]byte("second...\n"))
+
+	assert.Equal(t, 10, n)
+	assert.Equal(t, OUTPUT_FILE_COULD_NOT_BE_ROTATED, err.Error())
+
+	// Once the rotation is possible again, it continues as usual
+	os.RemoveAll(path + ".1")
+	_, err = rf.Write([]byte("third....\n"))
+	assert.Nil(t, err)
+
+	current, _ := os.ReadFile(path)
+	backup, _ := os.ReadFile(path + ".1")
+
+	assert.Equal(t, "third....\n", string(current))
+	assert.Equal(t, "first....\nsecond...\n", string(backup))
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const REDACTED = "[REDACTED]"
+
+var (
+	// NewRelicKeyPatterns match the formats of the New Relic user, admin,
+	// insights and browser keys as well as the ingest license keys.
+	NewRelicKeyPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\bNR[A-Z]{2}-[A-Za-z0-9_-]{19,40}\b`),
+		regexp.MustCompile(`\b[A-Za-z0-9]{36}NRAL\b`),
+	}
+
+	// EmailPattern matches email addresses.
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// DefaultRedactedKeys are the attribute names of which the values
+	// are always redacted.
+	DefaultRedactedKeys = []string{
+		"apikey",
+		"licensekey",
+		"password",
+		"secret",
+		"token",
+		"authorization",
+	}
+)
+
+// Redactor masks sensitive data within log messages and attributes.
+// The values of the attributes with the configured key names are
+// masked completely, all other values are masked where they match
+// one of the configured patterns.
+type Redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func NewRedactor(
+	keys []string,
+	patterns ...*regexp.Regexp,
+) *Redactor {
+	r := &Redactor{
+		keys:     make(map[string]bool),
+		patterns: patterns,
+	}
+
+	for _, key := range keys {
+		r.keys[normalizeKey(key)] = true
+	}
+
+	return r
+}
+
+// NewDefaultRedactor creates a redactor with the built-in rules for
+// New Relic keys, email addresses and credential attributes.
+func NewDefaultRedactor() *Redactor {
+	patterns := make([]*regexp.Regexp, 0, len(NewRelicKeyPatterns)+1)
+	patterns = append(patterns, NewRelicKeyPatterns...)
+	patterns = append(patterns, EmailPattern)
+
+	return NewRedactor(DefaultRedactedKeys, patterns...)
+}
+
+// RedactString masks all parts of the given string which match
+// one of the patterns.
+func (r *Redactor) RedactString(
+	s string,
+) string {
+	if r == nil {
+		return s
+	}
+
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, REDACTED)
+	}
+	return s
+}
+
+// RedactAttributes returns a copy of the given attributes in which
+// the sensitive values are masked.
+func (r *Redactor) RedactAttributes(
+	attributes map[string]string,
+) map[string]string {
+	if r == nil {
+		return attributes
+	}
+
+	attrs := make(map[string]string, len(attributes))
+	for key, val := range attributes {
+		attrs[key] = r.redactAttribute(key, val)
+	}
+	return attrs
+}
+
+// redactValue masks typed values. Numbers, booleans and times are kept
+// as they are, errors, stringers and any other values are converted to
+// strings first so that they are matched against the patterns before
+// they reach an output.
+func (r *Redactor) redactValue(
+	key string,
+	val interface{},
+) interface{} {
+	if r == nil {
+		return val
+	}
+
+	if r.isSensitiveKey(key) {
+		return REDACTED
+	}
+
+	switch v := val.(type) {
+	case nil, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64, time.Time:
+		return v
+	case string:
+		return r.RedactString(v)
+	case error:
+		return r.RedactString(v.Error())
+	case fmt.Stringer:
+		return r.RedactString(v.String())
+	default:
+		return r.RedactString(fmt.Sprintf("%v", v))
+	}
+}
+
+func (r *Redactor) redactAttribute(
+	key string,
+	val string,
+) string {
+	if r.isSensitiveKey(key) {
+		return REDACTED
+	}
+	return r.RedactString(val)
+}
+
+// isSensitiveKey checks the whole key as well as its last segment
+// so that "tracker.apiKey" is treated the same as "apiKey".
+func (r *Redactor) isSensitiveKey(
+	key string,
+) bool {
+	if r.keys[normalizeKey(key)] {
+		return true
+	}
+
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return r.keys[normalizeKey(key[i+1:])]
+	}
+	return false
+}
+
+func normalizeKey(
+	key string,
+) string {
+	return strings.NewReplacer("-", "", "_", "", ".", "").
+		Replace(strings.ToLower(key))
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RedactNewRelicKeys(t *testing.T) {
+	r := NewDefaultRedactor()
+
+	userKey := "NRAK-ABCDEFGHIJKLMNOPQRSTUVWXYZ1"
+	licenseKey := "0123456789abcdef0123456789abcdef0123NRAL"
+
+	assert.Equal(t, "key: "+REDACTED, r.RedactString("key: "+userKey))
+	assert.Equal(t, "key: "+REDACTED, r.RedactString("key: "+licenseKey))
+}
+
+func Test_RedactEmails(t *testing.T) {
+	r := NewDefaultRedactor()
+
+	msg := r.RedactString("FROM Log WHERE user = 'john.doe@example.com'")
+
+	assert.Equal(t, "FROM Log WHERE user = '"+REDACTED+"'", msg)
+}
+
+func Test_RedactAttributesBySensitiveKey(t *testing.T) {
+	r := NewDefaultRedactor()
+
+	attrs := map[string]string{
+		"tracker.apiKey": "whatever",
+		"Api-Key":        "whatever",
+		"tracker.error":  "not sensitive",
+	}
+	redacted := r.RedactAttributes(attrs)
+
+	assert.Equal(t, REDACTED, redacted["tracker.apiKey"])
+	assert.Equal(t, REDACTED, redacted["Api-Key"])
+	assert.Equal(t, "not sensitive", redacted["tracker.error"])
+	assert.Equal(t, "whatever", attrs["Api-Key"])
+}
+
+func Test_RedactWithCustomRules(t *testing.T) {
+	r := NewRedactor(
+		[]string{"customer"},
+		regexp.MustCompile(`\d{4}-\d{4}`),
+	)
+
+	redacted := r.RedactAttributes(map[string]string{
+		"customer": "ACME",
+		"order":    "order 1234-5678",
+	})
+
+	assert.Equal(t, REDACTED, redacted["customer"])
+	assert.Equal(t, "order "+REDACTED, redacted["order"])
+}
+
+func Test_NilRedactorKeepsData(t *testing.T) {
+	var r *Redactor
+
+	assert.Equal(t, "msg", r.RedactString("msg"))
+	assert.Equal(t, "val", r.RedactAttributes(map[string]string{"key": "val"})["key"])
+}
+
+func Test_CommonAttributesAreRedacted(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{
+			"owner":  "john.doe@example.com",
+			"apiKey": "secret",
+			"team":   "observability",
+		},
+		WithRedaction(NewDefaultRedactor()),
+	)
+
+	common := logger.forwarder.createNewRelicLogs(nil)[0].Common.Attributes
+
+	assert.Equal(t, REDACTED, common["owner"])
+	assert.Equal(t, REDACTED, common["apiKey"])
+	assert.Equal(t, "observability", common["team"])
+}
@@ -0,0 +1,174 @@
+//go:build go1.21
+
+package internal
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SlogHandler is a slog.Handler which writes the records through the
+// logger so that they are forwarded to New Relic the same way as the
+// logs of LogWithFields. Groups are flattened into dot separated
+// attribute keys. It is only available with Go 1.21 and later.
+type SlogHandler struct {
+	logger *Logger
+	level  slog.Leveler
+	attrs  logrus.Fields
+	group  string
+}
+
+func NewSlogHandler(
+	logger *Logger,
+	opts *slog.HandlerOptions,
+) *SlogHandler {
+	h := &SlogHandler{
+		logger: logger,
+		attrs:  logrus.Fields{},
+	}
+
+	if opts != nil {
+		h.level = opts.Level
+	}
+
+	return h
+}
+
+func (h *SlogHandler) Enabled(
+	ctx context.Context,
+	level slog.Level,
+) bool {
+	if h.level != nil && level < h.level.Level() {
+		return false
+	}
+	return h.logger.log.IsLevelEnabled(toLogrusLevel(level))
+}
+
+func (h *SlogHandler) Handle(
+	ctx context.Context,
+	r slog.Record,
+) error {
+	fields := make(logrus.Fields, len(h.attrs)+r.NumAttrs())
+	for key, val := range h.attrs {
+		fields[key] = val
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		addSlogAttr(fields, h.group, a)
+		return true
+	})
+
+	pc := r.PC
+	if h.logger.noCaller {
+		pc = 0
+	}
+
+	h.logger.write(ctx, pc, toLogrusLevel(r.Level), r.Time, r.Message, fields)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(
+	attrs []slog.Attr,
+) slog.Handler {
+	child := h.clone()
+	for _, a := range attrs {
+		addSlogAttr(child.attrs, h.group, a)
+	}
+	return child
+}
+
+func (h *SlogHandler) WithGroup(
+	name string,
+) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	child := h.clone()
+	child.group = joinKey(h.group, name)
+	return child
+}
+
+func (h *SlogHandler) clone() *SlogHandler {
+	attrs := make(logrus.Fields, len(h.attrs))
+	for key, val := range h.attrs {
+		attrs[key] = val
+	}
+
+	return &SlogHandler{
+		logger: h.logger,
+		level:  h.level,
+		attrs:  attrs,
+		group:  h.group,
+	}
+}
+
+// addSlogAttr flattens the given attribute into the fields while
+// keeping the types of its values.
+func addSlogAttr(
+	fields logrus.Fields,
+	prefix string,
+	a slog.Attr,
+) {
+	a.Value = a.Value.Resolve()
+
+	// Empty attributes are ignored
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		for _, ga := range a.Value.Group() {
+			addSlogAttr(fields, joinKey(prefix, a.Key), ga)
+		}
+	case slog.KindString:
+		fields[joinKey(prefix, a.Key)] = a.Value.String()
+	case slog.KindInt64:
+		fields[joinKey(prefix, a.Key)] = a.Value.Int64()
+	case slog.KindUint64:
+		fields[joinKey(prefix, a.Key)] = a.Value.Uint64()
+	case slog.KindFloat64:
+		fields[joinKey(prefix, a.Key)] = a.Value.Float64()
+	case slog.KindBool:
+		fields[joinKey(prefix, a.Key)] = a.Value.Bool()
+	case slog.KindDuration:
+		fields[joinKey(prefix, a.Key)] = a.Value.Duration().String()
+	case slog.KindTime:
+		fields[joinKey(prefix, a.Key)] = a.Value.Time()
+	default:
+		fields[joinKey(prefix, a.Key)] = a.Value.Any()
+	}
+}
+
+// joinKey prefixes the key with the group. Inlined groups without a
+// key do not add a segment.
+func joinKey(
+	prefix string,
+	key string,
+) string {
+	if prefix == "" {
+		return key
+	}
+	if key == "" {
+		return prefix
+	}
+	return prefix + "." + key
+}
+
+func toLogrusLevel(
+	level slog.Level,
+) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	default:
+		return logrus.DebugLevel
+	}
+}
@@ -0,0 +1,94 @@
+//go:build go1.21
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SlogHandlerForwardsTypedAttributes(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	l := slog.New(NewSlogHandler(logger, nil))
+	l.With("tracker.job", "job").
+		WithGroup("query").
+		Warn("msg", "count", 3, "success", false, slog.Group("account", "id", 12345))
+
+	nrLogs := logger.forwarder.createNewRelicLogs(logger.forwarder.logs)
+	attrs := nrLogs[0].Logs[0].Attributes
+
+	assert.Equal(t, logrus.WarnLevel, logger.forwarder.logs[0].entry.Level)
+	assert.Equal(t, "msg", nrLogs[0].Logs[0].Message)
+	assert.Equal(t, "job", attrs["tracker.job"])
+	assert.Equal(t, int64(3), attrs["query.count"])
+	assert.Equal(t, false, attrs["query.success"])
+	assert.Equal(t, int64(12345), attrs["query.account.id"])
+	assert.Equal(t, "github.com/utr1903/newrelic-tracker-internal/logging.Test_SlogHandlerForwardsTypedAttributes", attrs[CODE_FUNCTION_ATTRIBUTE])
+}
+
+func Test_SlogHandlerRespectsLevels(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"ERROR",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	l := slog.New(NewSlogHandler(logger, nil))
+	l.Info("info")
+	l.Error("error")
+
+	assert.Equal(t, 1, len(logger.forwarder.logs))
+	assert.Equal(t, "error", logger.forwarder.logs[0].entry.Message)
+}
+
+func Test_SlogHandlerRespectsHandlerOptions(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+	)
+
+	h := NewSlogHandler(logger, &slog.HandlerOptions{Level: slog.LevelWarn})
+
+	assert.False(t, h.Enabled(context.Background(), slog.LevelInfo))
+	assert.True(t, h.Enabled(context.Background(), slog.LevelWarn))
+}
+
+func Test_SlogHandlerRedactsErrorAttributes(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{},
+		WithRedaction(NewDefaultRedactor()),
+		WithOutput(out),
+	)
+
+	userKey := "NRAK-ABCDEFGHIJKLMNOPQRSTUVWXYZ1"
+	l := slog.New(NewSlogHandler(logger, nil))
+	l.Error("msg", "err", errors.New("request of john.doe@example.com with "+userKey+" has failed"))
+
+	nrLogs := logger.forwarder.createNewRelicLogs(logger.forwarder.logs)
+	attrs := nrLogs[0].Logs[0].Attributes
+
+	expected := "request of " + REDACTED + " with " + REDACTED + " has failed"
+	assert.Equal(t, expected, attrs["err"])
+	assert.Contains(t, out.String(), expected)
+	assert.NotContains(t, out.String(), "john.doe@example.com")
+	assert.NotContains(t, out.String(), userKey)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
+
+const TRUNCATED_ATTRIBUTE = "tracker.truncated"
+
+// truncate cuts the string to the given amount of bytes without
+// splitting a multi-byte character. A limit of zero or less disables
+// the truncation.
+func truncate(
+	s string,
+	limit int,
+) (
+	string,
+	bool,
+) {
+	if limit <= 0 || len(s) <= limit {
+		return s, false
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut], true
+}
+
+// truncateCommonAttributes cuts the common attributes to the attribute
+// limit. It is called once all options are applied.
+func (f *forwarder) truncateCommonAttributes() {
+	truncated := false
+	for key, val := range f.commonAttributes {
+		if cut, ok := truncate(val, f.maxAttributeLength); ok {
+			f.commonAttributes[key] = cut
+			truncated = true
+		}
+	}
+
+	if truncated {
+		f.commonAttributes[TRUNCATED_ATTRIBUTE] = "true"
+	}
+}
+
+// logBatch holds the logs of a single request along with their
+// marshalled log blocks which make up the payload.
+type logBatch struct {
+	logs   []*bufferedLog
+	blocks []json.RawMessage
+}
+
+// createBatches splits the logs so that the payload of each batch
+// stays within the payload limit. A single log exceeding the limit on
+// its own is sent as a batch of its own. Each log block is marshalled
+// once, the measured bytes are sent as they are.
+func (f *forwarder) createBatches(
+	logs []*bufferedLog,
+) []logBatch {
+
+	// Size of the payload without any logs
+	empty, _ := json.Marshal(f.createNewRelicLogs(nil))
+
+	batches := make([]logBatch, 0, 1)
+	batch := logBatch{}
+	batchSize := len(empty)
+
+	for _, log := range logs {
+		// Logs which cannot be marshalled, for example because of NaN
+		// values, would fail every payload and are dropped instead
+		block, err := json.Marshal(f.createLogBlock(log))
+		if err != nil {
+			f.mu.Lock()
+			f.dropped++
+			f.mu.Unlock()
+			continue
+		}
+
+		// Including the separating comma
+		size := len(block) + 1
+
+		if f.maxPayloadBytes > 0 && len(batch.logs) > 0 && batchSize+size > f.maxPayloadBytes {
+			batches = append(batches, batch)
+			batch = logBatch{}
+			batchSize = len(empty)
+		}
+
+		batch.logs = append(batch.logs, log)
+		batch.blocks = append(batch.blocks, block)
+		batchSize += size
+	}
+
+	if len(batch.logs) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
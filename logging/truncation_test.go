@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TruncateRespectsMultiByteCharacters(t *testing.T) {
+	s, truncated := truncate("aöb", 2)
+
+	assert.True(t, truncated)
+	assert.Equal(t, "a", s)
+}
+
+func Test_OversizedLogsAreTruncated(t *testing.T) {
+	f := newForwarder(logrus.AllLevels, "licenseKey", "logsEndpoint", map[string]string{})
+	f.maxMessageLength = 5
+	f.maxAttributeLength = 3
+
+	e := newTestEntry("long message")
+	e.Data["short"] = "abc"
+	e.Data["long"] = "abcdef"
+	f.Fire(e)
+	f.Fire(newTestEntry("short"))
+
+	nrLogs := f.createNewRelicLogs(f.logs)
+	truncated := nrLogs[0].Logs[0]
+
+	assert.Equal(t, "long ", truncated.Message)
+	assert.Equal(t, "abc", truncated.Attributes["short"])
+	assert.Equal(t, "abc", truncated.Attributes["long"])
+	assert.Equal(t, true, truncated.Attributes[TRUNCATED_ATTRIBUTE])
+	assert.NotContains(t, nrLogs[0].Logs[1].Attributes, TRUNCATED_ATTRIBUTE)
+}
+
+func Test_OversizedPayloadsAreSplit(t *testing.T) {
+	requests := int32(0)
+	newrelicLogApiServerMock := newLogApiServerMock(http.StatusAccepted, &requests)
+	defer newrelicLogApiServerMock.Close()
+
+	f := newForwarder(logrus.AllLevels, "licenseKey", newrelicLogApiServerMock.URL, map[string]string{})
+	f.maxPayloadBytes = 2000
+
+	for i := 0; i < 3; i++ {
+		f.Fire(newTestEntry(strings.Repeat("x", 900)))
+	}
+
+	assert.Equal(t, 3, len(f.createBatches(f.logs)))
+
+	err := f.flush()
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func Test_CommonAttributesAreTruncated(t *testing.T) {
+	logger := NewLoggerWithForwarder(
+		"DEBUG",
+		"licenseKey",
+		"logsEndpoint",
+		map[string]string{
+			"long": "abcdef",
+		},
+		WithLimits(DEFAULT_MAX_MESSAGE_LENGTH, 4, DEFAULT_MAX_PAYLOAD_BYTES),
+	)
+
+	common := logger.forwarder.commonAttributes
+
+	assert.Equal(t, "abcd", common["long"])
+	assert.Equal(t, "true", common[TRUNCATED_ATTRIBUTE])
+}
+
+func Test_BatchesCarryMarshalledLogBlocks(t *testing.T) {
+	var payload []struct {
+		Logs []logBlock `json:"logs"`
+	}
+	newrelicLogApiServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			zr, _ := gzip.NewReader(r.Body)
+			json.NewDecoder(zr).Decode(&payload)
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	defer newrelicLogApiServerMock.Close()
+
+	f := newForwarder(logrus.AllLevels, "licenseKey", newrelicLogApiServerMock.URL, map[string]string{})
+	f.Fire(newTestEntry("first"))
+
+	// Logs which cannot be marshalled are dropped
+	invalid := newTestEntry("invalid")
+	invalid.Data["value"] = math.NaN()
+	f.Fire(invalid)
+
+	err := f.flush()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(payload[0].Logs))
+	assert.Equal(t, "first", payload[0].Logs[0].Message)
+	assert.Equal(t, uint64(1), f.droppedLogs())
+}
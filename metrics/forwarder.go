@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	enrichment "github.com/utr1903/newrelic-tracker-internal/enrichment"
 	logging "github.com/utr1903/newrelic-tracker-internal/logging"
 )
 
@@ -56,10 +58,36 @@ type IMetricForwarder interface {
 type MetricForwarder struct {
 	Logger           logging.ILogger
 	MetricObjects    []metricObject
+	mu               sync.Mutex
 	client           *http.Client
 	licenseKey       string
 	metricsEndpoint  string
 	commonAttributes map[string]string
+	redactor         *logging.Redactor
+}
+
+// Option configures the forwarder created by NewMetricForwarder.
+type Option func(*MetricForwarder)
+
+// WithEnrichers adds the attributes of the given enrichers to the
+// common block of the forwarded metrics in addition to the Kubernetes,
+// host and process metadata.
+func WithEnrichers(
+	enrichers ...enrichment.Enricher,
+) Option {
+	return func(mf *MetricForwarder) {
+		enrichment.Enrich(mf.commonAttributes, enrichers...)
+	}
+}
+
+// WithRedaction masks sensitive data within the common and the
+// metric attributes before they are forwarded.
+func WithRedaction(
+	r *logging.Redactor,
+) Option {
+	return func(mf *MetricForwarder) {
+		mf.redactor = r
+	}
 }
 
 func NewMetricForwarder(
@@ -67,9 +95,14 @@ func NewMetricForwarder(
 	licenseKey string,
 	metricsEndpoint string,
 	commonAttributes map[string]string,
+	opts ...Option,
 ) *MetricForwarder {
-	return &MetricForwarder{
-		Logger: logger,
+	commonAttributes = setCommonAttributes(commonAttributes)
+
+	mf := &MetricForwarder{
+		Logger: logger.With(map[string]string{
+			"tracker.package": "internal.metrics",
+		}),
 		MetricObjects: []metricObject{{
 			Common: &commonBlock{
 				Attributes: commonAttributes,
@@ -81,6 +114,37 @@ func NewMetricForwarder(
 		metricsEndpoint:  metricsEndpoint,
 		commonAttributes: commonAttributes,
 	}
+
+	for _, opt := range opts {
+		opt(mf)
+	}
+
+	if mf.redactor != nil {
+		mf.commonAttributes = mf.redactor.RedactAttributes(mf.commonAttributes)
+		mf.MetricObjects[0].Common.Attributes = mf.commonAttributes
+	}
+
+	return mf
+}
+
+func setCommonAttributes(
+	commonAttrs map[string]string,
+) map[string]string {
+
+	// Copy the given attributes
+	attrs := make(map[string]string)
+	for k, v := range commonAttrs {
+		attrs[k] = v
+	}
+
+	// Kubernetes metadata
+	enrichment.Enrich(attrs, enrichment.NewKubernetesEnricher())
+
+	// Host and process metadata which the given attributes take
+	// precedence over
+	enrichment.EnrichMissing(attrs, enrichment.NewHostEnricher())
+
+	return attrs
 }
 
 func (mf *MetricForwarder) AddMetric(
@@ -90,6 +154,9 @@ func (mf *MetricForwarder) AddMetric(
 	metricValue float64,
 	metricAttributes map[string]string,
 ) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
 	mf.MetricObjects[0].Metrics = append(
 		mf.MetricObjects[0].Metrics,
 		metricBlock{
@@ -97,29 +164,53 @@ func (mf *MetricForwarder) AddMetric(
 			Name:       metricName,
 			Type:       metricType,
 			Value:      metricValue,
-			Attributes: metricAttributes,
+			Attributes: mf.redactor.RedactAttributes(metricAttributes),
 		},
 	)
 }
 
 func (mf *MetricForwarder) Run() error {
-	mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_FORWARDING_METRICS,
-		map[string]string{
-			"tracker.package": "internal.metrics",
-			"tracker.file":    "forwarder.go",
-		})
-
-	if len(mf.MetricObjects[0].Metrics) == 0 {
-		mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_THERE_ARE_NO_METRICS_TO_SEND,
-			map[string]string{
-				"tracker.package": "internal.metrics",
-				"tracker.file":    "forwarder.go",
-			})
+	mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_FORWARDING_METRICS, nil)
+
+	// Take the metrics out so that the ones which are added in the
+	// meantime are kept for the next run
+	mf.mu.Lock()
+	metrics := mf.MetricObjects[0].Metrics
+	mf.MetricObjects[0].Metrics = []metricBlock{}
+	mf.mu.Unlock()
+
+	if len(metrics) == 0 {
+		mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_THERE_ARE_NO_METRICS_TO_SEND, nil)
 		return nil
 	}
 
+	err := mf.send(metrics)
+	if err != nil {
+		mf.restoreMetrics(metrics)
+		return err
+	}
+
+	mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_METRICS_ARE_FORWARDED, nil)
+
+	return nil
+}
+
+// restoreMetrics puts metrics which could not be forwarded back in
+// front of the buffer so that they are retried with the next run.
+func (mf *MetricForwarder) restoreMetrics(
+	metrics []metricBlock,
+) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	mf.MetricObjects[0].Metrics = append(metrics, mf.MetricObjects[0].Metrics...)
+}
+
+func (mf *MetricForwarder) send(
+	metrics []metricBlock,
+) error {
 	// Create zipped payload
-	payloadZipped, err := mf.createPayload()
+	payloadZipped, err := mf.createPayload(metrics)
 	if err != nil {
 		return err
 	}
@@ -131,12 +222,7 @@ func (mf *MetricForwarder) Run() error {
 		payloadZipped,
 	)
 	if err != nil {
-		mf.Logger.LogWithFields(logrus.ErrorLevel, METRICS_HTTP_REQUEST_COULD_NOT_BE_CREATED,
-			map[string]string{
-				"tracker.package": "internal.metrics",
-				"tracker.file":    "forwarder.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(mf.Logger, logrus.ErrorLevel, METRICS_HTTP_REQUEST_COULD_NOT_BE_CREATED, err, nil)
 		return err
 	}
 	req.Header.Add("Content-Type", "application/json")
@@ -146,55 +232,36 @@ func (mf *MetricForwarder) Run() error {
 	// Perform HTTP request
 	res, err := mf.client.Do(req)
 	if err != nil {
-		mf.Logger.LogWithFields(logrus.ErrorLevel, METRICS_HTTP_REQUEST_HAS_FAILED,
-			map[string]string{
-				"tracker.package": "internal.metrics",
-				"tracker.file":    "forwarder.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(mf.Logger, logrus.ErrorLevel, METRICS_HTTP_REQUEST_HAS_FAILED, err, nil)
 		return err
 	}
 	defer res.Body.Close()
 
 	// Check if call was successful
 	if res.StatusCode != http.StatusAccepted {
-		mf.Logger.LogWithFields(logrus.ErrorLevel, METRICS_NEW_RELIC_RETURNED_NOT_OK_STATUS,
-			map[string]string{
-				"tracker.package": "internal.metrics",
-				"tracker.file":    "forwarder.go",
-				"tracker.error":   METRICS_NEW_RELIC_RETURNED_NOT_OK_STATUS,
-			})
-		return errors.New(METRICS_NEW_RELIC_RETURNED_NOT_OK_STATUS)
+		err = errors.New(METRICS_NEW_RELIC_RETURNED_NOT_OK_STATUS)
+		logging.LogError(mf.Logger, logrus.ErrorLevel, METRICS_NEW_RELIC_RETURNED_NOT_OK_STATUS, err, nil)
+		return err
 	}
 
-	mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_METRICS_ARE_FORWARDED,
-		map[string]string{
-			"tracker.package": "internal.metrics",
-			"tracker.file":    "forwarder.go",
-		})
-
 	return nil
 }
 
-func (mf *MetricForwarder) createPayload() (
+func (mf *MetricForwarder) createPayload(
+	metrics []metricBlock,
+) (
 	*bytes.Buffer,
 	error,
 ) {
 	// Create payload
-	mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_CREATING_PAYLOAD,
-		map[string]string{
-			"tracker.package": "internal.metrics",
-			"tracker.file":    "forwarder.go",
-		})
+	mf.Logger.LogWithFields(logrus.DebugLevel, METRICS_CREATING_PAYLOAD, nil)
 
-	json, err := json.Marshal(mf.MetricObjects)
+	json, err := json.Marshal([]metricObject{{
+		Common:  mf.MetricObjects[0].Common,
+		Metrics: metrics,
+	}})
 	if err != nil {
-		mf.Logger.LogWithFields(logrus.ErrorLevel, METRICS_PAYLOAD_COULD_NOT_BE_CREATED,
-			map[string]string{
-				"tracker.package": "internal.metrics",
-				"tracker.file":    "forwarder.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(mf.Logger, logrus.ErrorLevel, METRICS_PAYLOAD_COULD_NOT_BE_CREATED, err, nil)
 		return nil, err
 	}
 
@@ -204,22 +271,12 @@ func (mf *MetricForwarder) createPayload() (
 	defer zw.Close()
 
 	if _, err = zw.Write(json); err != nil {
-		mf.Logger.LogWithFields(logrus.ErrorLevel, METRICS_PAYLOAD_COULD_NOT_BE_ZIPPED,
-			map[string]string{
-				"tracker.package": "internal.metrics",
-				"tracker.file":    "forwarder.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(mf.Logger, logrus.ErrorLevel, METRICS_PAYLOAD_COULD_NOT_BE_ZIPPED, err, nil)
 		return nil, err
 	}
 
 	if err = zw.Close(); err != nil {
-		mf.Logger.LogWithFields(logrus.ErrorLevel, METRICS_PAYLOAD_COULD_NOT_BE_ZIPPED,
-			map[string]string{
-				"tracker.package": "internal.metrics",
-				"tracker.file":    "forwarder.go",
-				"tracker.error":   err.Error(),
-			})
+		logging.LogError(mf.Logger, logrus.ErrorLevel, METRICS_PAYLOAD_COULD_NOT_BE_ZIPPED, err, nil)
 		return nil, err
 	}
 
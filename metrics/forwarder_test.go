@@ -9,6 +9,8 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	enrichment "github.com/utr1903/newrelic-tracker-internal/enrichment"
+	logging "github.com/utr1903/newrelic-tracker-internal/logging"
 )
 
 type loggerMock struct {
@@ -28,6 +30,12 @@ func (l *loggerMock) LogWithFields(
 	l.msgs = append(l.msgs, msg)
 }
 
+func (l *loggerMock) With(
+	attributes map[string]string,
+) logging.ILogger {
+	return l
+}
+
 func (l *loggerMock) Flush() error {
 	return nil
 }
@@ -58,7 +66,7 @@ func Test_CreatePayloadSucceeds(t *testing.T) {
 		map[string]string{},
 	)
 
-	_, err := mf.createPayload()
+	_, err := mf.createPayload([]metricBlock{})
 
 	assert.Nil(t, err)
 }
@@ -168,4 +176,40 @@ func Test_MetricApiRequestSucceeds(t *testing.T) {
 	err := mf.Run()
 
 	assert.Nil(t, err)
+	assert.Empty(t, mf.MetricObjects[0].Metrics)
+}
+
+func Test_EnrichersExtendCommonAttributes(t *testing.T) {
+	logger := newLoggerMock()
+
+	commonAttributes := map[string]string{
+		"key": "val",
+	}
+	mf := NewMetricForwarder(
+		logger,
+		"licenseKey",
+		"metricsEndpoint",
+		commonAttributes,
+		WithEnrichers(enrichment.EnricherFunc(func() map[string]string {
+			return map[string]string{"clusterName": "cluster"}
+		})),
+	)
+
+	attrs := mf.MetricObjects[0].Common.Attributes
+	assert.Equal(t, "val", attrs["key"])
+	assert.Equal(t, "cluster", attrs["clusterName"])
+	assert.Equal(t, 1, len(commonAttributes))
+}
+
+func Test_GivenHostnameTakesPrecedence(t *testing.T) {
+	mf := NewMetricForwarder(
+		newLoggerMock(),
+		"licenseKey",
+		"metricsEndpoint",
+		map[string]string{
+			"hostname": "given",
+		},
+	)
+
+	assert.Equal(t, "given", mf.MetricObjects[0].Common.Attributes["hostname"])
 }
@@ -0,0 +1,225 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logging "github.com/utr1903/newrelic-tracker-internal/logging"
+	metrics "github.com/utr1903/newrelic-tracker-internal/metrics"
+)
+
+const (
+	SHUTDOWN_SIGNAL_RECEIVED      = "shutdown signal received"
+	SHUTDOWN_FLUSHING_ON_SHUTDOWN = "flushing on shutdown"
+	SHUTDOWN_FLUSHING_HAS_FAILED  = "flushing has failed"
+	SHUTDOWN_DEADLINE_EXCEEDED    = "shutdown deadline exceeded"
+	SHUTDOWN_FLUSHERS_ARE_FLUSHED = "flushers are flushed"
+	SHUTDOWN_PANIC_RECOVERED      = "panic recovered"
+)
+
+// FlushFunc ships the buffered data of a forwarder within the deadline
+// of the given context.
+type FlushFunc func(ctx context.Context) error
+
+type flusher struct {
+	name  string
+	flush FlushFunc
+}
+
+// flushErrors joins the errors of the failing flush functions. It
+// unwraps to all of them like the errors joined by errors.Join.
+type flushErrors []error
+
+func (e flushErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e flushErrors) Unwrap() []error {
+	return e
+}
+
+// Coordinator flushes the registered forwarders when the process is
+// about to terminate, either because of a signal or because of a panic.
+type Coordinator struct {
+	Logger   logging.ILogger
+	timeout  time.Duration
+	mu       sync.Mutex
+	flushers []flusher
+	exit     func(code int)
+}
+
+func NewCoordinator(
+	logger logging.ILogger,
+	timeout time.Duration,
+) *Coordinator {
+	return &Coordinator{
+		Logger: logger.With(map[string]string{
+			"tracker.package": "internal.shutdown",
+		}),
+		timeout:  timeout,
+		flushers: make([]flusher, 0),
+		exit:     os.Exit,
+	}
+}
+
+// Register adds a flush function which is called on shutdown. The
+// functions are called in the reverse order of their registration.
+func (c *Coordinator) Register(
+	name string,
+	flush FlushFunc,
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.flushers = append(c.flushers, flusher{
+		name:  name,
+		flush: flush,
+	})
+}
+
+// RegisterLogger flushes the logger on shutdown. Loggers which can be
+// closed are closed so that their background forwarding is drained.
+// Register the logger first to have it flushed last.
+func (c *Coordinator) RegisterLogger(
+	logger logging.ILogger,
+) {
+	c.Register("logger", func(ctx context.Context) error {
+		if closer, ok := logger.(interface {
+			Close(ctx context.Context) error
+		}); ok {
+			return closer.Close(ctx)
+		}
+		return logger.Flush()
+	})
+}
+
+// RegisterMetricForwarder forwards the pending metrics on shutdown.
+func (c *Coordinator) RegisterMetricForwarder(
+	mf metrics.IMetricForwarder,
+) {
+	c.Register("metrics", func(ctx context.Context) error {
+		return mf.Run()
+	})
+}
+
+// Shutdown calls all flush functions until they are done or the
+// context is done. Failing functions do not prevent the others from
+// being called.
+func (c *Coordinator) Shutdown(
+	ctx context.Context,
+) error {
+	c.mu.Lock()
+	flushers := make([]flusher, len(c.flushers))
+	copy(flushers, c.flushers)
+	c.mu.Unlock()
+
+	c.Logger.LogWithFields(logrus.DebugLevel, SHUTDOWN_FLUSHING_ON_SHUTDOWN, nil)
+
+	errs := make([]error, 0)
+	for i := len(flushers) - 1; i >= 0; i-- {
+		f := flushers[i]
+
+		done := make(chan error, 1)
+		go func() {
+			done <- f.flush(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				logging.LogError(c.Logger, logrus.ErrorLevel, SHUTDOWN_FLUSHING_HAS_FAILED, err,
+					map[string]string{
+						"tracker.flusher": f.name,
+					})
+				errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+			}
+		case <-ctx.Done():
+			return errors.New(SHUTDOWN_DEADLINE_EXCEEDED)
+		}
+	}
+
+	if len(errs) > 0 {
+		return flushErrors(errs)
+	}
+
+	c.Logger.LogWithFields(logrus.DebugLevel, SHUTDOWN_FLUSHERS_ARE_FLUSHED, nil)
+	return nil
+}
+
+// Listen traps SIGINT and SIGTERM, flushes everything within the
+// timeout and exits the process with the conventional 128+signal code.
+// The returned function stops listening.
+func (c *Coordinator) Listen() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			c.Logger.LogWithFields(logrus.DebugLevel, SHUTDOWN_SIGNAL_RECEIVED,
+				map[string]string{
+					"tracker.signal": sig.String(),
+				})
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+			defer cancel()
+			c.Shutdown(ctx)
+
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s)
+			}
+			c.exit(code)
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}
+}
+
+// HandlePanic logs a panic, flushes everything within the timeout and
+// panics again. It has to be deferred directly:
+//
+//	defer coordinator.HandlePanic()
+func (c *Coordinator) HandlePanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("%v", r)
+	}
+
+	logging.LogError(c.Logger, logrus.ErrorLevel, SHUTDOWN_PANIC_RECOVERED, err,
+		map[string]string{
+			logging.ERROR_STACK_ATTRIBUTE: string(debug.Stack()),
+		})
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.Shutdown(ctx)
+
+	panic(r)
+}
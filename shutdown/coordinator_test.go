@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	logging "github.com/utr1903/newrelic-tracker-internal/logging"
+	metrics "github.com/utr1903/newrelic-tracker-internal/metrics"
+)
+
+type metricForwarderMock struct {
+	runs int
+}
+
+func (mf *metricForwarderMock) AddMetric(
+	metricTimestamp int64,
+	metricName string,
+	metricType string,
+	metricValue float64,
+	metricAttributes map[string]string,
+) {
+}
+
+func (mf *metricForwarderMock) Run() error {
+	mf.runs++
+	return nil
+}
+
+func Test_ShutdownFlushesInReverseOrder(t *testing.T) {
+	c := NewCoordinator(logging.NewNoopLogger(), time.Second)
+
+	order := make([]string, 0)
+	c.Register("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	c.Register("second", func(ctx context.Context) error {
+		order = append(order, "second")
+		return nil
+	})
+
+	err := c.Shutdown(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"second", "first"}, order)
+}
+
+func Test_ShutdownContinuesAfterFailure(t *testing.T) {
+	c := NewCoordinator(logging.NewNoopLogger(), time.Second)
+
+	mf := &metricForwarderMock{}
+	c.RegisterMetricForwarder(mf)
+	c.Register("failing", func(ctx context.Context) error {
+		return errors.New("error")
+	})
+
+	err := c.Shutdown(context.Background())
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, mf.runs)
+}
+
+func Test_ShutdownDoesNotResendForwardedMetrics(t *testing.T) {
+	requests := 0
+	newrelicMetricApiServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	defer newrelicMetricApiServerMock.Close()
+
+	mf := metrics.NewMetricForwarder(
+		logging.NewNoopLogger(),
+		"licenseKey",
+		newrelicMetricApiServerMock.URL,
+		map[string]string{},
+	)
+
+	c := NewCoordinator(logging.NewNoopLogger(), time.Second)
+	c.RegisterMetricForwarder(mf)
+
+	// Metrics which are already forwarded by the tracker
+	mf.AddMetric(time.Now().UnixMicro(), "forwarded", "gauge", 1.0, nil)
+	assert.Nil(t, mf.Run())
+
+	err := c.Shutdown(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, requests)
+
+	// Pending metrics are forwarded on shutdown
+	mf.AddMetric(time.Now().UnixMicro(), "pending", "gauge", 1.0, nil)
+	err = c.Shutdown(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, requests)
+	assert.Empty(t, mf.MetricObjects[0].Metrics)
+}
+
+func Test_ShutdownWhileMetricsAreAdded(t *testing.T) {
+	var mu sync.Mutex
+	received := 0
+	newrelicMetricApiServerMock := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			zr, _ := gzip.NewReader(r.Body)
+			payload := []struct {
+				Metrics []any `json:"metrics"`
+			}{}
+			json.NewDecoder(zr).Decode(&payload)
+
+			mu.Lock()
+			received += len(payload[0].Metrics)
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	defer newrelicMetricApiServerMock.Close()
+
+	mf := metrics.NewMetricForwarder(
+		logging.NewNoopLogger(),
+		"licenseKey",
+		newrelicMetricApiServerMock.URL,
+		map[string]string{},
+	)
+
+	c := NewCoordinator(logging.NewNoopLogger(), time.Second)
+	c.RegisterMetricForwarder(mf)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			mf.AddMetric(time.Now().UnixMicro(), "metric", "gauge", 1.0, nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			c.Shutdown(context.Background())
+		}
+	}()
+	wg.Wait()
+
+	// Forward whatever is left after both are done
+	err := c.Shutdown(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 200, received)
+}
+
+func Test_ShutdownRespectsDeadline(t *testing.T) {
+	c := NewCoordinator(logging.NewNoopLogger(), time.Second)
+
+	c.Register("blocking", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := c.Shutdown(ctx)
+
+	assert.Equal(t, SHUTDOWN_DEADLINE_EXCEEDED, err.Error())
+}
+
+func Test_SignalTriggersShutdown(t *testing.T) {
+	c := NewCoordinator(logging.NewNoopLogger(), time.Second)
+
+	mf := &metricForwarderMock{}
+	c.RegisterMetricForwarder(mf)
+
+	exitCodes := make(chan int, 1)
+	c.exit = func(code int) {
+		exitCodes <- code
+	}
+
+	stop := c.Listen()
+	defer stop()
+
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(syscall.SIGTERM)
+
+	select {
+	case code := <-exitCodes:
+		assert.Equal(t, 128+int(syscall.SIGTERM), code)
+		assert.Equal(t, 1, mf.runs)
+	case <-time.After(time.Second):
+		t.Fatal("shutdown was not triggered")
+	}
+}
+
+func Test_HandlePanicFlushesAndPanicsAgain(t *testing.T) {
+	c := NewCoordinator(logging.NewNoopLogger(), time.Second)
+
+	mf := &metricForwarderMock{}
+	c.RegisterMetricForwarder(mf)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer c.HandlePanic()
+		panic("boom")
+	})
+	assert.Equal(t, 1, mf.runs)
+}